@@ -0,0 +1,38 @@
+package reenvoy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+)
+
+// appArmorEnabledFile reports whether the AppArmor module of the kernel is
+// enabled. It is missing on hosts without AppArmor.
+var appArmorEnabledFile = "/sys/module/apparmor/parameters/enabled"
+
+// appArmorEnabled returns whether AppArmor is enabled on the host.
+func appArmorEnabled() bool {
+	data, err := ioutil.ReadFile(appArmorEnabledFile)
+	return err == nil && strings.TrimSpace(string(data)) == "Y"
+}
+
+// appArmorCommand wraps command in aa-exec so it is confined by
+// AppArmorProfile. Without AppArmor on the host, the command is returned as is
+// when AppArmorFallbackToPermissive is set, and an error otherwise.
+func (r *Process) appArmorCommand(command string, args []string) (string, []string, error) {
+	if !appArmorEnabled() {
+		if !r.AppArmorFallbackToPermissive {
+			return "", nil, fmt.Errorf("apparmor profile %q requested but apparmor is not enabled on this host", r.AppArmorProfile)
+		}
+
+		log.Printf("[WARN] apparmor is not enabled, running %q unconfined", command)
+		return command, args, nil
+	}
+
+	return "aa-exec", append([]string{
+		"--profile=" + r.AppArmorProfile,
+		"--",
+		command,
+	}, args...), nil
+}
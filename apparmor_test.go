@@ -0,0 +1,39 @@
+package reenvoy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppArmorCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(file string) { appArmorEnabledFile = file }(appArmorEnabledFile)
+	appArmorEnabledFile = filepath.Join(dir, "enabled")
+
+	c := testProcess(t)
+	c.AppArmorProfile = "envoy"
+
+	// AppArmor is missing from the host.
+	_, _, err = c.appArmorCommand("envoy", nil)
+	assert.NotNil(t, err)
+
+	c.AppArmorFallbackToPermissive = true
+	command, args, err := c.appArmorCommand("envoy", []string{"-c", "envoy.yaml"})
+	require.Nil(t, err)
+	assert.Equal(t, "envoy", command)
+	assert.Equal(t, []string{"-c", "envoy.yaml"}, args)
+
+	require.Nil(t, ioutil.WriteFile(appArmorEnabledFile, []byte("Y\n"), 0644))
+	command, args, err = c.appArmorCommand("envoy", []string{"-c", "envoy.yaml"})
+	require.Nil(t, err)
+	assert.Equal(t, "aa-exec", command)
+	assert.Equal(t, []string{"--profile=envoy", "--", "envoy", "-c", "envoy.yaml"}, args)
+}
@@ -0,0 +1,113 @@
+package reenvoy
+
+import (
+	"log"
+	"math"
+	"time"
+)
+
+// RestartCount returns the number of automatic restarts since the count was
+// last reset by RestartWindow.
+func (r *Process) RestartCount() int {
+	r.RLock()
+	defer r.RUnlock()
+	return r.restarts
+}
+
+// autoRestart restarts the process after the run ending with waitCh exited
+// with code on its own, reusing exitCh. It reports whether the process was
+// restarted; otherwise the exit is to be delivered on exitCh.
+func (r *Process) autoRestart(waitCh chan struct{}, exitCh chan int, code int) bool {
+	// With a Timeout, start itself waits for the exit of the command.
+	if r.MaxRestarts == 0 || r.Timeout != 0 {
+		return false
+	}
+
+	// stopLock is only held while checking stopped, not across the waits,
+	// which Stop cuts short by closing stopCh once it holds the lock.
+	r.stopLock.RLock()
+	if r.stopped {
+		r.stopLock.RUnlock()
+		return false
+	}
+
+	r.Lock()
+	r.stopLock.RUnlock()
+	if !r.exitedOnItsOwn(waitCh) {
+		r.Unlock()
+		return false
+	}
+
+	if r.RestartWindow > 0 && r.now().Sub(r.startedAt) >= r.RestartWindow {
+		r.restarts = 0
+	}
+
+	if r.MaxRestarts > 0 && r.restarts >= r.MaxRestarts {
+		log.Printf("[WARN] process exited with code %d, giving up after %d restarts", code, r.restarts)
+		r.Unlock()
+		return false
+	}
+
+	r.restarts++
+	attempt := r.restarts
+	delay := r.restartDelay(attempt)
+	if pause := r.spawnRatePause(r.now()); pause > delay {
+		log.Printf("[WARN] spawn rate exceeded, pausing automatic restart for %s", pause)
+		delay = pause
+	}
+	stopCh := r.stopCh
+	pid := int(r.pid())
+	r.Unlock()
+
+	r.emit(ProcessEvent{Type: EventRestartBackoff, PID: pid, Attempt: attempt})
+
+	log.Printf("[INFO] process exited with code %d, restarting in %s (attempt %d)", code, delay, attempt)
+	select {
+	case <-stopCh:
+		return false
+	case <-r.after(delay):
+	}
+
+	select {
+	case <-stopCh:
+		return false
+	case <-r.randomSplay():
+	}
+
+	r.stopLock.RLock()
+	defer r.stopLock.RUnlock()
+	r.Lock()
+	defer r.Unlock()
+
+	// Stopped or killed while waiting.
+	if r.stopped || !r.exitedOnItsOwn(waitCh) {
+		return false
+	}
+
+	r.release()
+	r.restartExitCh = exitCh
+	if err := r.start(); err != nil {
+		log.Printf("[WARN] automatic restart failed: %s", err)
+		r.restartExitCh = nil
+		return false
+	}
+
+	r.emit(ProcessEvent{Type: EventRestarted, PID: int(r.pid()), Attempt: attempt})
+	return true
+}
+
+// exitedOnItsOwn reports whether the run ending with waitCh is still the
+// current one and was not killed. It must be called with the lock held.
+func (r *Process) exitedOnItsOwn(waitCh chan struct{}) bool {
+	return r.waitCh == waitCh && r.running()
+}
+
+// restartDelay returns the delay before the given automatic restart attempt,
+// starting at 1.
+func (r *Process) restartDelay(attempt int) time.Duration {
+	factor := r.RestartBackoffFactor
+	if factor < 1 {
+		factor = 1
+	}
+	return time.Duration(float64(r.RestartDelay) * math.Pow(factor, float64(attempt-1)))
+}
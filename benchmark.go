@@ -0,0 +1,182 @@
+package reenvoy
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BenchmarkStats summarises the runs of a process in benchmark mode.
+type BenchmarkStats struct {
+	// Runs is the number of runs measured.
+	Runs int
+
+	// FirstByte is the latency from exec until the first byte written to
+	// stdout or stderr. Runs that produced no output are not counted.
+	FirstByte LatencyStats
+
+	// Total is the latency from exec until the process exited.
+	Total LatencyStats
+}
+
+// LatencyStats holds the distribution of a set of latencies.
+type LatencyStats struct {
+	Mean time.Duration
+	P50  time.Duration
+	P95  time.Duration
+	P99  time.Duration
+	Max  time.Duration
+}
+
+// BenchmarkResults returns the statistics of the last benchmark run, or nil
+// when no benchmark has completed.
+func (r *Process) BenchmarkResults() *BenchmarkStats {
+	r.RLock()
+	defer r.RUnlock()
+	return r.benchmarkStats
+}
+
+// runBenchmark runs the command BenchmarkRuns times serially and records
+// the latency statistics.
+func (r *Process) runBenchmark() error {
+	// Nothing keeps running afterwards, so let go of the lock and temp dir.
+	defer r.release()
+
+	runs := r.BenchmarkRuns
+	if runs < 1 {
+		runs = 1
+	}
+
+	var firstBytes, totals []time.Duration
+	for i := 0; i < runs; i++ {
+		firstByte, total, err := r.benchmarkOnce()
+		if err != nil {
+			return fmt.Errorf("benchmark run %d err: %s", i+1, err)
+		}
+
+		if firstByte > 0 {
+			firstBytes = append(firstBytes, firstByte)
+		}
+		totals = append(totals, total)
+	}
+
+	r.benchmarkStats = &BenchmarkStats{
+		Runs:      runs,
+		FirstByte: latencyStats(firstBytes),
+		Total:     latencyStats(totals),
+	}
+
+	log.Printf("[INFO] benchmark of %d runs: first byte p50 %s p99 %s, total p50 %s p99 %s",
+		runs, r.benchmarkStats.FirstByte.P50, r.benchmarkStats.FirstByte.P99,
+		r.benchmarkStats.Total.P50, r.benchmarkStats.Total.P99)
+	return nil
+}
+
+// benchmarkOnce runs the command to completion and returns the time until
+// its first output byte (zero if it wrote nothing) and until it exited.
+func (r *Process) benchmarkOnce() (time.Duration, time.Duration, error) {
+	command, args, err := r.commandLine()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	first := &firstByteWriter{}
+	stdout, stderr := r.outputs()
+	cmd := exec.Command(command, args...)
+	cmd.Stdin = r.input()
+	cmd.Stdout = first.wrap(stdout)
+	cmd.Stderr = first.wrap(stderr)
+	cmd.Env = r.childEnv()
+
+	begin := time.Now()
+	if err := cmd.Start(); err != nil {
+		return 0, 0, err
+	}
+
+	if r.Timeout != 0 {
+		timer := time.AfterFunc(r.Timeout, func() { cmd.Process.Kill() })
+		defer timer.Stop()
+	}
+
+	// The exit status does not matter for latency, only that it exited.
+	cmd.Wait()
+	total := time.Since(begin)
+
+	return first.since(begin), total, nil
+}
+
+// firstByteWriter records when the first byte is written through any of the
+// writers it wraps.
+type firstByteWriter struct {
+	sync.Mutex
+	at time.Time
+}
+
+func (f *firstByteWriter) wrap(w io.Writer) io.Writer {
+	if w == nil {
+		w = ioutil.Discard
+	}
+
+	return writerFunc(func(p []byte) (int, error) {
+		f.Lock()
+		if f.at.IsZero() && len(p) > 0 {
+			f.at = time.Now()
+		}
+		f.Unlock()
+		return w.Write(p)
+	})
+}
+
+func (f *firstByteWriter) since(begin time.Time) time.Duration {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.at.IsZero() {
+		return 0
+	}
+	return f.at.Sub(begin)
+}
+
+// writerFunc adapts a function to io.Writer.
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+// latencyStats computes the distribution of samples using the nearest-rank
+// percentile.
+func latencyStats(samples []time.Duration) LatencyStats {
+	if len(samples) == 0 {
+		return LatencyStats{}
+	}
+
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var sum time.Duration
+	for _, s := range sorted {
+		sum += s
+	}
+
+	percentile := func(p int) time.Duration {
+		i := (p*len(sorted)+99)/100 - 1
+		if i < 0 {
+			i = 0
+		}
+		return sorted[i]
+	}
+
+	return LatencyStats{
+		Mean: sum / time.Duration(len(sorted)),
+		P50:  percentile(50),
+		P95:  percentile(95),
+		P99:  percentile(99),
+		Max:  sorted[len(sorted)-1],
+	}
+}
@@ -0,0 +1,50 @@
+package reenvoy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-gatedio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmarkMode(t *testing.T) {
+	t.Parallel()
+
+	c := testProcess(t)
+	c.BenchmarkMode = true
+	c.BenchmarkRuns = 3
+
+	out := gatedio.NewByteBuffer()
+	c.Stdout = out
+
+	assert.Nil(t, c.BenchmarkResults())
+	require.Nil(t, c.Start())
+
+	stats := c.BenchmarkResults()
+	require.NotNil(t, stats)
+	assert.Equal(t, 3, stats.Runs)
+	assert.True(t, stats.FirstByte.P50 > 0)
+	assert.True(t, stats.FirstByte.Max <= stats.Total.Max)
+	assert.Equal(t, "hello world\nhello world\nhello world\n", out.String())
+}
+
+func TestLatencyStats(t *testing.T) {
+	t.Parallel()
+
+	var samples []time.Duration
+	for i := 100; i > 0; i-- {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+
+	stats := latencyStats(samples)
+	assert.Equal(t, LatencyStats{
+		Mean: 50500 * time.Microsecond,
+		P50:  50 * time.Millisecond,
+		P95:  95 * time.Millisecond,
+		P99:  99 * time.Millisecond,
+		Max:  100 * time.Millisecond,
+	}, stats)
+	assert.Equal(t, LatencyStats{}, latencyStats(nil))
+}
@@ -0,0 +1,93 @@
+package reenvoy
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// binaryPollInterval is how often the binary is checked for updates.
+var binaryPollInterval = time.Second
+
+// binaryPath returns the path of the binary watched for updates.
+func (r *Process) binaryPath() (string, error) {
+	if r.BinaryPath != "" {
+		return r.BinaryPath, nil
+	}
+
+	command := r.Command
+	if command == "" {
+		command, _ = r.commandEnvoy()
+	}
+	return exec.LookPath(command)
+}
+
+// watchBinary starts polling the binary of the process and restarts the
+// process once an update has settled. The watch ends when the process exits.
+func (r *Process) watchBinary(waitCh <-chan struct{}) {
+	path, err := r.binaryPath()
+	if err != nil {
+		log.Printf("[WARN] unable to watch binary: %s", err)
+		return
+	}
+
+	started, err := os.Stat(path)
+	if err != nil {
+		log.Printf("[WARN] unable to watch binary: %s", err)
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(binaryPollInterval)
+		defer ticker.Stop()
+
+		var seen os.FileInfo
+		var since time.Time
+		for {
+			select {
+			case <-waitCh:
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(path)
+			if err != nil || sameFile(info, started) {
+				// Missing while being replaced, or unchanged.
+				continue
+			}
+
+			if seen == nil || !sameFile(info, seen) {
+				seen, since = info, time.Now()
+			}
+
+			if time.Since(since) >= r.BinaryUpdateDebounce {
+				log.Printf("[INFO] binary %s changed, restarting process", path)
+				r.restartForBinary()
+				return
+			}
+		}
+	}()
+}
+
+// sameFile reports whether a and b describe the same version of a file.
+func sameFile(a, b os.FileInfo) bool {
+	return a.ModTime().Equal(b.ModTime()) && a.Size() == b.Size() && os.SameFile(a, b)
+}
+
+// restartForBinary restarts the process unless it was stopped meanwhile.
+func (r *Process) restartForBinary() {
+	r.stopLock.RLock()
+	defer r.stopLock.RUnlock()
+
+	if r.stopped {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	if err := r.restart(); err != nil {
+		log.Printf("[ERR] failed to restart process with new binary: %s", err)
+	}
+}
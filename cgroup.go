@@ -0,0 +1,35 @@
+package reenvoy
+
+// CgroupV1Config holds the resource limits applied to a process through a
+// cgroup. Zero values leave the corresponding limit unset.
+type CgroupV1Config struct {
+	// CPUShares is the relative CPU weight, cpu.shares in cgroup v1.
+	CPUShares int64
+
+	// MemoryLimitBytes is the hard memory limit, memory.limit_in_bytes.
+	MemoryLimitBytes int64
+
+	// BlkioWeight is the relative block IO weight (10-1000), blkio.weight.
+	BlkioWeight int
+
+	// CpusetCPUs is the list of CPUs the process may run on, e.g. "0-3,6".
+	CpusetCPUs string
+}
+
+// cpuWeight converts cpu.shares (2-262144) to the cgroup v2 cpu.weight
+// (1-10000) range.
+func cpuWeight(shares int64) int64 {
+	if shares < 2 {
+		shares = 2
+	}
+	return 1 + ((shares-2)*9999)/262142
+}
+
+// ioWeight converts blkio.weight (10-1000) to the cgroup v2 io.weight
+// (1-10000) range.
+func ioWeight(weight int) int {
+	if weight < 10 {
+		weight = 10
+	}
+	return 1 + ((weight-10)*9999)/990
+}
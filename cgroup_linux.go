@@ -0,0 +1,169 @@
+//go:build linux
+// +build linux
+
+package reenvoy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cgroupRoot is where the cgroup hierarchies are mounted.
+var cgroupRoot = "/sys/fs/cgroup"
+
+// cgroupV2 reports whether cgroupRoot holds the unified cgroup v2 hierarchy.
+func cgroupV2() bool {
+	_, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers"))
+	return err == nil
+}
+
+// applyCgroup creates a cgroup for pid, applies CgroupV1Config to it and
+// moves pid into it.
+func (r *Process) applyCgroup(pid int) error {
+	if r.CgroupV1Config == nil {
+		return nil
+	}
+
+	name := fmt.Sprintf("reenvoy-%d", pid)
+	if r.CgroupAutoDetect && cgroupV2() {
+		return r.applyCgroupV2(name, pid)
+	}
+	return r.applyCgroupV1(name, pid)
+}
+
+func (r *Process) applyCgroupV1(name string, pid int) error {
+	cfg := r.CgroupV1Config
+	limits := map[string]map[string]string{}
+	set := func(controller, file, value string) {
+		if limits[controller] == nil {
+			limits[controller] = map[string]string{}
+		}
+		limits[controller][file] = value
+	}
+
+	if cfg.CPUShares > 0 {
+		set("cpu", "cpu.shares", strconv.FormatInt(cfg.CPUShares, 10))
+	}
+	if cfg.MemoryLimitBytes > 0 {
+		set("memory", "memory.limit_in_bytes", strconv.FormatInt(cfg.MemoryLimitBytes, 10))
+	}
+	if cfg.BlkioWeight > 0 {
+		set("blkio", "blkio.weight", strconv.Itoa(cfg.BlkioWeight))
+	}
+	if cfg.CpusetCPUs != "" {
+		// cpuset refuses tasks until its memory nodes are set, inherit them.
+		mems, err := ioutil.ReadFile(filepath.Join(cgroupRoot, "cpuset", "cpuset.mems"))
+		if err != nil {
+			return fmt.Errorf("cgroup v1 cpuset err: %s", err)
+		}
+		set("cpuset", "cpuset.cpus", cfg.CpusetCPUs)
+		set("cpuset", "cpuset.mems", strings.TrimSpace(string(mems)))
+	}
+
+	for controller, files := range limits {
+		if _, err := os.Stat(filepath.Join(cgroupRoot, controller)); err != nil {
+			return fmt.Errorf("cgroup v1 %s controller is not available: %s", controller, err)
+		}
+
+		dir := filepath.Join(cgroupRoot, controller, name)
+		if err := os.Mkdir(dir, 0755); err != nil {
+			return fmt.Errorf("create cgroup %s err: %s", dir, err)
+		}
+		r.cgroupPaths = append(r.cgroupPaths, dir)
+
+		// Limits are written in a fixed order, all before the task joins.
+		for _, file := range []string{"cpu.shares", "memory.limit_in_bytes", "blkio.weight", "cpuset.cpus", "cpuset.mems"} {
+			if value, ok := files[file]; ok {
+				if err := writeCgroupFile(dir, file, value); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := writeCgroupFile(dir, "cgroup.procs", strconv.Itoa(pid)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Process) applyCgroupV2(name string, pid int) error {
+	cfg := r.CgroupV1Config
+	var controllers []string
+	files := map[string]string{}
+
+	if cfg.CPUShares > 0 {
+		controllers = append(controllers, "+cpu")
+		files["cpu.weight"] = strconv.FormatInt(cpuWeight(cfg.CPUShares), 10)
+	}
+	if cfg.MemoryLimitBytes > 0 {
+		controllers = append(controllers, "+memory")
+		files["memory.max"] = strconv.FormatInt(cfg.MemoryLimitBytes, 10)
+	}
+	if cfg.BlkioWeight > 0 {
+		controllers = append(controllers, "+io")
+		files["io.weight"] = "default " + strconv.Itoa(ioWeight(cfg.BlkioWeight))
+	}
+	if cfg.CpusetCPUs != "" {
+		controllers = append(controllers, "+cpuset")
+		files["cpuset.cpus"] = cfg.CpusetCPUs
+	}
+
+	if len(controllers) > 0 {
+		if err := writeCgroupFile(cgroupRoot, "cgroup.subtree_control", strings.Join(controllers, " ")); err != nil {
+			return err
+		}
+	}
+
+	dir := filepath.Join(cgroupRoot, name)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return fmt.Errorf("create cgroup %s err: %s", dir, err)
+	}
+	r.cgroupPaths = append(r.cgroupPaths, dir)
+
+	for _, file := range []string{"cpu.weight", "memory.max", "io.weight", "cpuset.cpus"} {
+		if value, ok := files[file]; ok {
+			if err := writeCgroupFile(dir, file, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return writeCgroupFile(dir, "cgroup.procs", strconv.Itoa(pid))
+}
+
+func writeCgroupFile(dir, file, value string) error {
+	if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(value), 0644); err != nil {
+		return fmt.Errorf("write cgroup %s/%s err: %s", dir, file, err)
+	}
+	return nil
+}
+
+// removeCgroup deletes the cgroups created for the process once it has been
+// reaped; the kernel refuses to remove a cgroup that still has tasks.
+func (r *Process) removeCgroup() {
+	if len(r.cgroupPaths) == 0 {
+		return
+	}
+
+	paths, waitCh := r.cgroupPaths, r.waitCh
+	r.cgroupPaths = nil
+
+	go func() {
+		if waitCh != nil {
+			<-waitCh
+		}
+
+		for _, dir := range paths {
+			if err := os.Remove(dir); err != nil {
+				log.Printf("[WARN] failed to remove cgroup %s: %s", dir, err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,78 @@
+package reenvoy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCgroupRoot(t *testing.T, dirs ...string) func() {
+	root, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+
+	for _, dir := range dirs {
+		require.Nil(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+
+	old := cgroupRoot
+	cgroupRoot = root
+	return func() {
+		cgroupRoot = old
+		os.RemoveAll(root)
+	}
+}
+
+func readCgroupFile(t *testing.T, path ...string) string {
+	b, err := ioutil.ReadFile(filepath.Join(append([]string{cgroupRoot}, path...)...))
+	require.Nil(t, err)
+	return string(b)
+}
+
+func TestCgroupV1(t *testing.T) {
+	defer testCgroupRoot(t, "cpu", "memory", "cpuset")()
+	require.Nil(t, ioutil.WriteFile(filepath.Join(cgroupRoot, "cpuset", "cpuset.mems"), []byte("0\n"), 0644))
+
+	c := testProcess(t)
+	c.CgroupV1Config = &CgroupV1Config{
+		CPUShares:        512,
+		MemoryLimitBytes: 1 << 20,
+		CpusetCPUs:       "0-1",
+	}
+
+	require.Nil(t, c.applyCgroup(42))
+	assert.Len(t, c.cgroupPaths, 3)
+	assert.Equal(t, "512", readCgroupFile(t, "cpu", "reenvoy-42", "cpu.shares"))
+	assert.Equal(t, "1048576", readCgroupFile(t, "memory", "reenvoy-42", "memory.limit_in_bytes"))
+	assert.Equal(t, "0-1", readCgroupFile(t, "cpuset", "reenvoy-42", "cpuset.cpus"))
+	assert.Equal(t, "0", readCgroupFile(t, "cpuset", "reenvoy-42", "cpuset.mems"))
+	assert.Equal(t, "42", readCgroupFile(t, "memory", "reenvoy-42", "cgroup.procs"))
+
+	// blkio is not mounted
+	c = testProcess(t)
+	c.CgroupV1Config = &CgroupV1Config{BlkioWeight: 100}
+	assert.NotNil(t, c.applyCgroup(43))
+}
+
+func TestCgroupAutoDetect(t *testing.T) {
+	defer testCgroupRoot(t)()
+	require.Nil(t, ioutil.WriteFile(filepath.Join(cgroupRoot, "cgroup.controllers"), []byte("cpu io memory"), 0644))
+
+	c := testProcess(t)
+	c.CgroupAutoDetect = true
+	c.CgroupV1Config = &CgroupV1Config{
+		CPUShares:        1024,
+		MemoryLimitBytes: 1 << 20,
+		BlkioWeight:      500,
+	}
+
+	require.Nil(t, c.applyCgroup(42))
+	assert.Equal(t, "+cpu +memory +io", readCgroupFile(t, "cgroup.subtree_control"))
+	assert.Equal(t, "39", readCgroupFile(t, "reenvoy-42", "cpu.weight"))
+	assert.Equal(t, "1048576", readCgroupFile(t, "reenvoy-42", "memory.max"))
+	assert.Equal(t, "default 4950", readCgroupFile(t, "reenvoy-42", "io.weight"))
+	assert.Equal(t, "42", readCgroupFile(t, "reenvoy-42", "cgroup.procs"))
+}
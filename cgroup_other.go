@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package reenvoy
+
+import "errors"
+
+// applyCgroup fails when limits are requested, cgroups only exist on Linux.
+func (r *Process) applyCgroup(pid int) error {
+	if r.CgroupV1Config == nil {
+		return nil
+	}
+	return errors.New("cgroups are not supported on this platform")
+}
+
+func (r *Process) removeCgroup() {}
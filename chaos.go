@@ -0,0 +1,37 @@
+package reenvoy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// ErrChaos is the error injected by ChaosMode.
+var ErrChaos = errors.New("chaos: injected failure")
+
+// chaosMaxDelay bounds the delays injected by ChaosMode.
+var chaosMaxDelay = 500 * time.Millisecond
+
+// chaos injects a failure into the lifecycle method op with a probability of
+// ChaosProbability when ChaosMode is set: a random delay, an error wrapping
+// ErrChaos, or a panic.
+func (r *Process) chaos(op string) error {
+	if !r.ChaosMode || rand.Float64() >= r.ChaosProbability {
+		return nil
+	}
+
+	switch rand.Intn(3) {
+	case 0:
+		d := time.Duration(rand.Int63n(int64(chaosMaxDelay) + 1))
+		log.Printf("[DEBUG] chaos: delaying %s by %s", op, d)
+		time.Sleep(d)
+		return nil
+	case 1:
+		log.Printf("[DEBUG] chaos: failing %s", op)
+		return fmt.Errorf("%w in %s", ErrChaos, op)
+	default:
+		panic(fmt.Sprintf("chaos: injected panic in %s", op))
+	}
+}
@@ -0,0 +1,46 @@
+package reenvoy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChaos(t *testing.T) {
+	defer func(d time.Duration) { chaosMaxDelay = d }(chaosMaxDelay)
+	chaosMaxDelay = time.Millisecond
+
+	c := testProcess(t)
+	c.ChaosProbability = 1
+
+	// Without ChaosMode, the probability has no effect.
+	require.Nil(t, c.chaos("Start"))
+
+	c.ChaosMode = true
+	var delays, errs, panics int
+	for i := 0; i < 100; i++ {
+		func() {
+			defer func() {
+				if recover() != nil {
+					panics++
+				}
+			}()
+
+			if err := c.chaos("Start"); err != nil {
+				assert.True(t, errors.Is(err, ErrChaos))
+				errs++
+			} else {
+				delays++
+			}
+		}()
+	}
+	assert.True(t, delays > 0 && errs > 0 && panics > 0, "delays %d, errors %d, panics %d", delays, errs, panics)
+
+	c.ChaosProbability = 0
+	for i := 0; i < 100; i++ {
+		require.Nil(t, c.chaos("Start"))
+	}
+}
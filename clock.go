@@ -0,0 +1,43 @@
+package reenvoy
+
+import (
+	"os"
+	"time"
+)
+
+// Clock is the source of time of a Process, replaceable in TestMode.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the Clock of the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// clock returns the TestClock in TestMode, and the real clock otherwise.
+func (r *Process) clock() Clock {
+	if r.TestMode && r.TestClock != nil {
+		return r.TestClock
+	}
+	return realClock{}
+}
+
+func (r *Process) now() time.Time {
+	return r.clock().Now()
+}
+
+func (r *Process) after(d time.Duration) <-chan time.Time {
+	return r.clock().After(d)
+}
+
+// deliverTestSignal reports whether s is to be passed to TestSignalDelivery
+// instead of the child, and the error it returned.
+func (r *Process) deliverTestSignal(s os.Signal) (bool, error) {
+	if !r.TestMode || r.TestSignalDelivery == nil {
+		return false, nil
+	}
+	return true, r.TestSignalDelivery(s)
+}
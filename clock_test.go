@@ -0,0 +1,142 @@
+package reenvoy
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a Clock only moving forward when advanced.
+type fakeClock struct {
+	sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(0, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.Lock()
+	defer c.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{c.now.Add(d), ch})
+	return ch
+}
+
+// Advance moves the clock forward by d, firing the waiters due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.now = c.now.Add(d)
+	waiters := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = waiters
+}
+
+// waitForWaiter blocks until something waits on the clock.
+func (c *fakeClock) waitForWaiter(t *testing.T) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		c.Lock()
+		n := len(c.waiters)
+		c.Unlock()
+		if n > 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("nothing waited on the clock")
+}
+
+func TestTestMode(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	signals := make(chan os.Signal, 10)
+
+	c := testProcess(t)
+	c.Command = "sleep"
+	c.Args = []string{"1"}
+	c.ReloadSignal = syscall.SIGHUP
+	c.KillSignal = syscall.SIGTERM
+	c.Splay = time.Hour
+	c.TestMode = true
+	c.TestClock = clock
+	c.TestSignalDelivery = func(s os.Signal) error {
+		signals <- s
+		return nil
+	}
+
+	require.Nil(t, c.Start())
+
+	// The reload waits for the splay on the fake clock.
+	done := make(chan error, 1)
+	go func() { done <- c.Restart() }()
+
+	clock.waitForWaiter(t)
+	select {
+	case <-done:
+		t.Fatal("restart should wait for the splay")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	clock.Advance(time.Hour)
+	select {
+	case err := <-done:
+		require.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("restart should end once the clock passed the splay")
+	}
+	assert.Equal(t, syscall.SIGHUP, <-signals)
+
+	// Stopping waits for the splay and the kill timeout on the fake clock too.
+	stopped := make(chan struct{})
+	go func() {
+		c.Stop()
+		close(stopped)
+	}()
+
+	clock.waitForWaiter(t)
+	clock.Advance(time.Hour)
+	assert.Equal(t, syscall.SIGTERM, <-signals)
+
+	clock.waitForWaiter(t)
+	clock.Advance(c.KillTimeout)
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("stop should end once the clock passed the kill timeout")
+	}
+	assert.Equal(t, os.Kill, <-signals)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/evo3cx/reenvoy"
+)
+
+// completion returns the generator of the completion script for shell.
+func completion(shell string) (func(w io.Writer, flags *flag.FlagSet), bool) {
+	switch shell {
+	case "bash":
+		return bashCompletion, true
+	case "zsh":
+		return zshCompletion, true
+	case "fish":
+		return fishCompletion, true
+	}
+	return nil, false
+}
+
+// runCompletion prints the completion script of the shell given by --shell.
+//
+//	source <(reenvoy completion --shell bash)
+func runCompletion(args []string) int {
+	var shell string
+	flags := flag.NewFlagSet("reenvoy completion", flag.ContinueOnError)
+	flags.StringVar(&shell, "shell", "bash", "shell to complete: bash, zsh or fish")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	gen, ok := completion(shell)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unsupported shell %q\n", shell)
+		return 2
+	}
+
+	gen(os.Stdout, newFlags(&reenvoy.Process{}, &options{}))
+	return 0
+}
+
+// isBoolFlag reports whether f takes no value.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func bashCompletion(w io.Writer, flags *flag.FlagSet) {
+	var names, subs []string
+	flags.VisitAll(func(f *flag.Flag) {
+		names = append(names, "--"+f.Name)
+	})
+	for _, sub := range subcommands {
+		subs = append(subs, sub.name)
+	}
+
+	fmt.Fprintf(w, `_reenvoy() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    if [[ $COMP_CWORD -eq 1 && $cur != -* ]]; then
+        COMPREPLY=( $(compgen -W "%s" -- "$cur") )
+        return
+    fi
+    if [[ $cur == -* ]]; then
+        COMPREPLY=( $(compgen -W "%s" -- "$cur") )
+    fi
+}
+complete -o default -F _reenvoy reenvoy
+`, strings.Join(subs, " "), strings.Join(names, " "))
+}
+
+func zshCompletion(w io.Writer, flags *flag.FlagSet) {
+	var buf bytes.Buffer
+	flags.VisitAll(func(f *flag.Flag) {
+		spec := fmt.Sprintf("--%s[%s]", f.Name, zshEscape(f.Usage))
+		if !isBoolFlag(f) {
+			spec += ":" + f.Name + ":_default"
+		}
+		fmt.Fprintf(&buf, "    '%s' \\\n", spec)
+	})
+
+	var subs []string
+	for _, sub := range subcommands {
+		subs = append(subs, sub.name+`\:"`+zshEscape(sub.usage)+`"`)
+	}
+
+	fmt.Fprintf(w, "#compdef reenvoy\n\n_arguments \\\n%s    '1::subcommand:((%s))' \\\n    '*::argument:_default'\n",
+		buf.String(), strings.Join(subs, " "))
+}
+
+// zshEscape escapes the characters with a meaning in _arguments specs and
+// single quotes.
+func zshEscape(s string) string {
+	return strings.NewReplacer(`[`, `\[`, `]`, `\]`, `:`, `\:`, `'`, `'\''`).Replace(s)
+}
+
+func fishCompletion(w io.Writer, flags *flag.FlagSet) {
+	for _, sub := range subcommands {
+		fmt.Fprintf(w, "complete -c reenvoy -n __fish_use_subcommand -f -a %s -d %s\n", sub.name, fishQuote(sub.usage))
+	}
+
+	flags.VisitAll(func(f *flag.Flag) {
+		arg := " -r"
+		if isBoolFlag(f) {
+			arg = ""
+		}
+		fmt.Fprintf(w, "complete -c reenvoy -l %s -d %s%s\n", f.Name, fishQuote(f.Usage), arg)
+	})
+}
+
+// fishQuote returns s as a single quoted fish string.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
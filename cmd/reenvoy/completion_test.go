@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"testing"
+
+	"github.com/evo3cx/reenvoy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompletion(t *testing.T) {
+	flags := newFlags(&reenvoy.Process{}, &options{})
+
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		gen, ok := completion(shell)
+		require.True(t, ok, shell)
+
+		var buf bytes.Buffer
+		gen(&buf, flags)
+
+		assert.Contains(t, buf.String(), "kill-timeout", shell)
+		assert.Contains(t, buf.String(), "completion", shell)
+	}
+}
+
+func TestCompletion_bashSyntax(t *testing.T) {
+	var buf bytes.Buffer
+	bashCompletion(&buf, newFlags(&reenvoy.Process{}, &options{}))
+
+	cmd := exec.Command("bash", "-n")
+	cmd.Stdin = &buf
+	out, err := cmd.CombinedOutput()
+	require.Nil(t, err, string(out))
+}
@@ -0,0 +1,164 @@
+// Command reenvoy runs a command, envoy by default, under the supervision of
+// a reenvoy.Process.
+//
+//	reenvoy --command nginx --args "-g 'daemon off;'" --auto-restart
+//
+// Arguments after the flags are appended to --args. SIGHUP restarts the
+// process, or reloads it when a reload signal is given, and SIGINT or
+// SIGTERM stop it.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/evo3cx/reenvoy"
+	"github.com/hashicorp/logutils"
+)
+
+// options are the settings of the supervisor itself, as opposed to those of
+// the process.
+type options struct {
+	args         string
+	reloadSignal string
+	killSignal   string
+
+	autoRestart bool
+	maxRestarts int
+	logLevel    string
+}
+
+// subcommands are run in place of the supervisor when given as the first
+// argument.
+var subcommands = []struct {
+	name  string
+	usage string
+}{
+	{"completion", "print a shell completion script"},
+	{"version", "print the build information"},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "completion":
+			return runCompletion(args[1:])
+		case "version":
+			return runVersion(args[1:])
+		}
+	}
+
+	p, opts, err := parseFlags(args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	log.SetOutput(&logutils.LevelFilter{
+		Levels:   []logutils.LogLevel{"DEBUG", "INFO", "WARN", "ERR"},
+		MinLevel: logutils.LogLevel(strings.ToUpper(opts.logLevel)),
+		Writer:   os.Stderr,
+	})
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+
+	if err := p.Start(); err != nil {
+		log.Printf("[ERR] failed to start process: %s", err)
+		return 1
+	}
+
+	for {
+		select {
+		case sig := <-signals:
+			if sig != syscall.SIGHUP {
+				log.Printf("[INFO] received %q, stopping", sig)
+				p.Stop()
+				return 0
+			}
+
+			log.Printf("[INFO] received %q, restarting", sig)
+			if err := p.Restart(); err != nil {
+				log.Printf("[ERR] failed to restart process: %s", err)
+				p.Stop()
+				return 1
+			}
+
+		case code := <-p.ExitCh():
+			log.Printf("[INFO] process exited with code %d", code)
+			p.Stop()
+			return code
+		}
+	}
+}
+
+// parseFlags builds the process and the supervisor options from the command
+// line.
+func parseFlags(args []string) (*reenvoy.Process, *options, error) {
+	p := &reenvoy.Process{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		StdErr: os.Stderr,
+	}
+	opts := &options{}
+
+	flags := newFlags(p, opts)
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	p.Args = append(strings.Fields(opts.args), flags.Args()...)
+
+	var err error
+	if p.ReloadSignal, err = reenvoy.ParseSignal(opts.reloadSignal); err != nil {
+		return nil, nil, err
+	}
+
+	if p.KillSignal, err = reenvoy.ParseSignal(opts.killSignal); err != nil {
+		return nil, nil, err
+	}
+
+	if opts.autoRestart {
+		p.MaxRestarts = opts.maxRestarts
+		if p.MaxRestarts == 0 {
+			p.MaxRestarts = -1
+		}
+	}
+
+	return p, opts, nil
+}
+
+// newFlags returns the flags of the supervisor, set into p and opts.
+func newFlags(p *reenvoy.Process, opts *options) *flag.FlagSet {
+	flags := flag.NewFlagSet("reenvoy", flag.ContinueOnError)
+	flags.StringVar(&p.Command, "command", "", "command to run, envoy when empty")
+	flags.StringVar(&opts.args, "args", "", "space separated arguments of the command")
+	flags.StringVar(&p.ConfigPath, "config-path", "", "directory of envoy.yaml when running envoy")
+	flags.BoolVar(&p.DockerContainer, "docker", false, "run envoy in docker")
+	flags.DurationVar(&p.DrainTimes, "drain-time", 0, "envoy drain time on hot restart")
+	flags.DurationVar(&p.ParentShutdownTimes, "parent-shutdown-time", 0, "envoy parent shutdown time on hot restart")
+	flags.StringVar(&opts.reloadSignal, "reload-signal", "", "signal sent to reload the process, restarts it when empty")
+	flags.StringVar(&opts.killSignal, "kill-signal", "SIGTERM", "signal sent to stop the process")
+	flags.DurationVar(&p.KillTimeout, "kill-timeout", 2*time.Second, "time to wait for the process to stop before killing it")
+	flags.DurationVar(&p.Splay, "splay", 0, "maximum random delay before signalling the process")
+	flags.BoolVar(&opts.autoRestart, "auto-restart", false, "restart the process when it exits")
+	flags.IntVar(&opts.maxRestarts, "max-restarts", 0, "number of automatic restarts before giving up, 0 for no limit")
+	flags.DurationVar(&p.RestartDelay, "restart-delay", 0, "delay before an automatic restart")
+	flags.Float64Var(&p.RestartBackoffFactor, "restart-backoff", 1, "factor applied to the restart delay after each automatic restart")
+	flags.StringVar(&p.PIDFile, "pid-file", "", "file to write the PID of the process to")
+	flags.StringVar(&opts.logLevel, "log-level", "INFO", "minimum level of the logs: DEBUG, INFO, WARN or ERR")
+	return flags
+}
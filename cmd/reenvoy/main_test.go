@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFlags(t *testing.T) {
+	p, opts, err := parseFlags([]string{
+		"--command", "bash",
+		"--args", "-c",
+		"--reload-signal", "HUP",
+		"--kill-timeout", "5s",
+		"--auto-restart",
+		"--max-restarts", "3",
+		"--pid-file", "/tmp/reenvoy.pid",
+		"exit 0",
+	})
+	require.Nil(t, err)
+
+	assert.Equal(t, "bash", p.Command)
+	assert.Equal(t, []string{"-c", "exit 0"}, p.Args)
+	assert.Equal(t, os.Signal(syscall.SIGHUP), p.ReloadSignal)
+	assert.Equal(t, os.Signal(syscall.SIGTERM), p.KillSignal)
+	assert.Equal(t, 5*time.Second, p.KillTimeout)
+	assert.True(t, opts.autoRestart)
+	assert.Equal(t, 3, opts.maxRestarts)
+	assert.Equal(t, "/tmp/reenvoy.pid", p.PIDFile)
+}
+
+func TestParseFlags_badSignal(t *testing.T) {
+	_, _, err := parseFlags([]string{"--kill-signal", "SIGNOPE"})
+	assert.NotNil(t, err)
+}
+
+func TestRun_maxRestarts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	count := dir + "/count"
+	code := run([]string{
+		"--command", "bash",
+		"--auto-restart",
+		"--max-restarts", "2",
+		"--", "-c", "echo x >> " + count + "; exit 3",
+	})
+	assert.Equal(t, 3, code)
+
+	data, err := ioutil.ReadFile(count)
+	require.Nil(t, err)
+	assert.Equal(t, "x\nx\nx\n", string(data))
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/evo3cx/reenvoy"
+)
+
+// runVersion prints the build information, as JSON with --json.
+func runVersion(args []string) int {
+	var asJSON bool
+	flags := flag.NewFlagSet("reenvoy version", flag.ContinueOnError)
+	flags.BoolVar(&asJSON, "json", false, "print the build information as JSON")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	if err := printVersion(os.Stdout, asJSON); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func printVersion(w io.Writer, asJSON bool) error {
+	info := reenvoy.BuildInfo()
+	if asJSON {
+		return json.NewEncoder(w).Encode(info)
+	}
+
+	_, err := fmt.Fprintf(w, "reenvoy %s\ngit commit: %s\nbuild date: %s\ngo version: %s\n",
+		info["version"], info["git_commit"], info["build_date"], info["go_version"])
+	return err
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/evo3cx/reenvoy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintVersion(t *testing.T) {
+	var buf bytes.Buffer
+	require.Nil(t, printVersion(&buf, false))
+	assert.Contains(t, buf.String(), "reenvoy "+reenvoy.Version)
+
+	buf.Reset()
+	require.Nil(t, printVersion(&buf, true))
+
+	var info map[string]string
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &info))
+	assert.Equal(t, reenvoy.BuildInfo(), info)
+}
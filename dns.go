@@ -0,0 +1,100 @@
+package reenvoy
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// prepareResolvConf writes the resolv.conf of the child from DNSServers and
+// DNSSearchDomains, in TempDir when set.
+func (r *Process) prepareResolvConf() error {
+	if len(r.DNSServers) == 0 && len(r.DNSSearchDomains) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, server := range r.DNSServers {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("dns server %q is not an IP address", server)
+		}
+		fmt.Fprintf(&buf, "nameserver %s\n", server)
+	}
+	if len(r.DNSSearchDomains) > 0 {
+		fmt.Fprintf(&buf, "search %s\n", strings.Join(r.DNSSearchDomains, " "))
+	}
+
+	var f *os.File
+	var err error
+	if r.TempDir != "" {
+		f, err = os.Create(filepath.Join(r.TempDir, "resolv.conf"))
+	} else {
+		f, err = ioutil.TempFile("", "resolv.conf")
+	}
+	if err != nil {
+		return fmt.Errorf("create resolv.conf err: %s", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("write resolv.conf err: %s", err)
+	}
+
+	// The file must be readable by the child, whatever user it runs as.
+	if err := f.Chmod(0644); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("write resolv.conf err: %s", err)
+	}
+
+	r.resolvConf = f.Name()
+	return nil
+}
+
+// removeResolvConf deletes the resolv.conf of the child once it is gone.
+func (r *Process) removeResolvConf() {
+	if r.resolvConf == "" {
+		return
+	}
+
+	if err := os.Remove(r.resolvConf); err != nil && !os.IsNotExist(err) {
+		log.Printf("[WARN] failed to remove %s: %s", r.resolvConf, err)
+	}
+	r.resolvConf = ""
+}
+
+// dnsEnv returns env extended with RESOLV_CONF, pointing at the resolv.conf
+// of the child, and LOCALDOMAIN, which glibc and musl use as the search list.
+func (r *Process) dnsEnv(env []string) []string {
+	if r.resolvConf == "" {
+		return env
+	}
+
+	if env == nil {
+		env = os.Environ()
+	}
+
+	env = append(env[:len(env):len(env)], "RESOLV_CONF="+r.resolvConf)
+	if len(r.DNSSearchDomains) > 0 {
+		env = append(env, "LOCALDOMAIN="+strings.Join(r.DNSSearchDomains, " "))
+	}
+	return env
+}
+
+// dnsCommand wraps command so it runs in a new mount namespace where the
+// resolv.conf of the child is bind mounted over /etc/resolv.conf.
+func (r *Process) dnsCommand(command string, args []string) (string, []string) {
+	return "unshare", append([]string{
+		"--mount",
+		"--propagation", "private",
+		"--",
+		"sh", "-c", `mount --bind "$0" /etc/resolv.conf && exec "$@"`,
+		r.resolvConf,
+		command,
+	}, args...)
+}
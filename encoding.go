@@ -0,0 +1,72 @@
+package reenvoy
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// Encodings of the standard streams of the child.
+const (
+	// EncodingRaw passes the data through unchanged.
+	EncodingRaw = "raw"
+
+	// EncodingBase64 encodes every write of the child as a line of standard
+	// base64, and decodes standard base64 input, ignoring newlines.
+	EncodingBase64 = "base64"
+
+	// EncodingHex encodes output and decodes input as lowercase hexadecimal.
+	EncodingHex = "hex"
+)
+
+// checkEncodings returns an error if any stream encoding of the process is
+// unknown.
+func (r *Process) checkEncodings() error {
+	for _, enc := range []string{r.StdinEncoding, r.StdoutEncoding, r.StderrEncoding} {
+		switch enc {
+		case "", EncodingRaw, EncodingBase64, EncodingHex:
+		default:
+			return fmt.Errorf("unknown stream encoding %q", enc)
+		}
+	}
+	return nil
+}
+
+// encodeWriter returns w wrapped to encode what is written to it.
+func encodeWriter(w io.Writer, encoding string) io.Writer {
+	if w == nil {
+		return nil
+	}
+
+	switch encoding {
+	case EncodingBase64:
+		return writerFunc(func(p []byte) (int, error) {
+			buf := make([]byte, base64.StdEncoding.EncodedLen(len(p))+1)
+			base64.StdEncoding.Encode(buf, p)
+			buf[len(buf)-1] = '\n'
+			if _, err := w.Write(buf); err != nil {
+				return 0, err
+			}
+			return len(p), nil
+		})
+	case EncodingHex:
+		return hex.NewEncoder(w)
+	}
+	return w
+}
+
+// decodeReader returns r wrapped to decode what is read from it.
+func decodeReader(r io.Reader, encoding string) io.Reader {
+	if r == nil {
+		return nil
+	}
+
+	switch encoding {
+	case EncodingBase64:
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case EncodingHex:
+		return hex.NewDecoder(r)
+	}
+	return r
+}
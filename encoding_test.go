@@ -0,0 +1,40 @@
+package reenvoy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamEncoding(t *testing.T) {
+	t.Parallel()
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "cat; printf 'ab' >&2"}
+	c.Stdin = strings.NewReader(base64.StdEncoding.EncodeToString([]byte("\x00\x01binary")) + "\n")
+	c.Stdout = stdout
+	c.StdErr = stderr
+	c.StdinEncoding = EncodingBase64
+	c.StdoutEncoding = EncodingHex
+	c.StderrEncoding = EncodingBase64
+
+	require.Nil(t, c.Start())
+	<-c.ExitCh()
+
+	assert.Equal(t, "000162696e617279", stdout.String())
+	assert.Equal(t, "YWI=\n", stderr.String())
+}
+
+func TestStreamEncoding_unknown(t *testing.T) {
+	t.Parallel()
+
+	c := testProcess(t)
+	c.StdoutEncoding = "rot13"
+	assert.NotNil(t, c.Start())
+}
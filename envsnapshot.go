@@ -0,0 +1,96 @@
+package reenvoy
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// EnvChangeType tells how a variable changed between two environments.
+type EnvChangeType int
+
+const (
+	// EnvAdded is a variable missing from the environment at start.
+	EnvAdded EnvChangeType = iota
+	// EnvRemoved is a variable no longer present in the snapshot.
+	EnvRemoved
+	// EnvModified is a variable present in both with different values.
+	EnvModified
+)
+
+// EnvChange describes a variable that differs between the environment the
+// process was started with and a snapshot of its live environment.
+type EnvChange struct {
+	Type EnvChangeType
+	Key  string
+	Old  string
+	New  string
+}
+
+// SnapshotEnv returns the live environment of the running process, read from
+// /proc/PID/environ. It is only supported on Linux.
+func (r *Process) SnapshotEnv() (map[string]string, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	pid := r.pid()
+	if pid == 0 {
+		return nil, ErrNotRunning
+	}
+
+	b, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/environ", pid))
+	if err != nil {
+		return nil, err
+	}
+
+	var env []string
+	for _, kv := range bytes.Split(b, []byte{0}) {
+		if len(kv) > 0 {
+			env = append(env, string(kv))
+		}
+	}
+	return envMap(env), nil
+}
+
+// EnvDiff compares snapshot, as returned by SnapshotEnv, with the environment
+// the process was started with. The changes are sorted by key.
+func (r *Process) EnvDiff(snapshot map[string]string) []EnvChange {
+	r.RLock()
+	start := envMap(r.startEnv)
+	r.RUnlock()
+
+	var changes []EnvChange
+	for key, old := range start {
+		cur, ok := snapshot[key]
+		switch {
+		case !ok:
+			changes = append(changes, EnvChange{Type: EnvRemoved, Key: key, Old: old})
+		case cur != old:
+			changes = append(changes, EnvChange{Type: EnvModified, Key: key, Old: old, New: cur})
+		}
+	}
+
+	for key, cur := range snapshot {
+		if _, ok := start[key]; !ok {
+			changes = append(changes, EnvChange{Type: EnvAdded, Key: key, New: cur})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// envMap converts an environment in "key=value" form to a map; later
+// duplicates win, as they do for exec.
+func envMap(env []string) map[string]string {
+	m := make(map[string]string, len(env))
+	for _, kv := range env {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 {
+			m[parts[0]] = parts[1]
+		}
+	}
+	return m
+}
@@ -0,0 +1,66 @@
+package reenvoy
+
+import (
+	"os"
+	"time"
+)
+
+// ProcessEventType is the kind of a ProcessEvent.
+type ProcessEventType int
+
+const (
+	// EventStarted is emitted once a child started.
+	EventStarted ProcessEventType = iota
+
+	// EventRestarted is emitted once a child replaced the previous one,
+	// through Restart or an automatic restart.
+	EventRestarted
+
+	// EventSignaled is emitted when a signal is sent with Signal or
+	// UrgentSignal.
+	EventSignaled
+
+	// EventKilled is emitted when the child is about to be stopped by Kill,
+	// Stop or a restart.
+	EventKilled
+
+	// EventExited is emitted once the child exited, for whatever reason.
+	EventExited
+
+	// EventRestartBackoff is emitted when an automatic restart is scheduled,
+	// before waiting out its delay.
+	EventRestartBackoff
+)
+
+// ProcessEvent is a lifecycle transition of a Process, passed to OnEvent.
+type ProcessEvent struct {
+	Type ProcessEventType
+
+	// PID is the child the event is about: the new one for EventRestarted
+	// and the exited one for EventRestartBackoff.
+	PID int
+
+	// Signal is the signal sent for EventSignaled and the first signal sent
+	// to stop the child for EventKilled.
+	Signal os.Signal
+
+	// ExitError is the error returned while waiting for the child, for
+	// EventExited.
+	ExitError error
+
+	// Attempt is the number of the automatic restart, starting at 1, for
+	// EventRestartBackoff and EventRestarted.
+	Attempt int
+
+	Timestamp time.Time
+}
+
+// emit passes e to OnEvent, if set.
+func (r *Process) emit(e ProcessEvent) {
+	if r.OnEvent == nil {
+		return
+	}
+
+	e.Timestamp = r.now()
+	r.OnEvent(e)
+}
@@ -0,0 +1,20 @@
+package reenvoy
+
+import (
+	"os"
+	"time"
+)
+
+// ExitEvent describes how a child exited.
+type ExitEvent struct {
+	// ProcessState is the state of the reaped child. It is nil for podman
+	// containers and wasm modules, which are not OS processes of ours.
+	ProcessState *os.ProcessState
+
+	// Err is the error returned while waiting for the child, e.g. an
+	// *exec.ExitError for a non-zero exit status.
+	Err error
+
+	PID  int
+	Time time.Time
+}
@@ -0,0 +1,158 @@
+package reenvoy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FDInfo describes a file descriptor open in the process.
+type FDInfo struct {
+	FD int
+
+	// Type is "file", "socket", "pipe" or "anon_inode".
+	Type string
+
+	// Name is the path of a file, or the inode of sockets and pipes.
+	Name string
+
+	// Pos is the file offset and Flags the flags the descriptor was opened
+	// with, like os.O_RDONLY, read from /proc/PID/fdinfo.
+	Pos   int64
+	Flags int
+}
+
+// FDChange is a file descriptor found in only one of two snapshots.
+type FDChange struct {
+	FDInfo
+
+	// Opened is true when the descriptor appeared in the later snapshot and
+	// false when it was closed since the earlier one.
+	Opened bool
+}
+
+// SnapshotFDs lists the file descriptors open in the running process, read
+// from /proc/PID/fd and /proc/PID/fdinfo. It is only supported on Linux.
+func (r *Process) SnapshotFDs() ([]FDInfo, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	pid := r.pid()
+	if pid == 0 {
+		return nil, ErrNotRunning
+	}
+
+	dir := fmt.Sprintf("/proc/%d/fd", pid)
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fds := make([]FDInfo, 0, len(entries))
+	for _, e := range entries {
+		fd, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+
+		target, err := os.Readlink(filepath.Join(dir, e.Name()))
+		if err != nil {
+			// Closed while we were listing.
+			continue
+		}
+
+		info := fdInfo(fd, target)
+		if err := readFDInfo(pid, &info); err != nil {
+			continue
+		}
+		fds = append(fds, info)
+	}
+
+	sort.Slice(fds, func(i, j int) bool { return fds[i].FD < fds[j].FD })
+	return fds, nil
+}
+
+// fdInfo classifies the link target of a /proc/PID/fd entry, which looks
+// like "socket:[1234]" for anything but regular files.
+func fdInfo(fd int, target string) FDInfo {
+	for _, typ := range []string{"socket", "pipe", "anon_inode"} {
+		if strings.HasPrefix(target, typ+":") {
+			return FDInfo{FD: fd, Type: typ, Name: strings.TrimPrefix(target, typ+":")}
+		}
+	}
+	return FDInfo{FD: fd, Type: "file", Name: target}
+}
+
+// readFDInfo fills the position and flags of info from
+// /proc/PID/fdinfo/FD, which looks like "pos:\t0\nflags:\t0100002\n...".
+func readFDInfo(pid PID, info *FDInfo) error {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/fdinfo/%d", pid, info.FD))
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "pos:":
+			info.Pos, err = strconv.ParseInt(fields[1], 10, 64)
+		case "flags:":
+			var flags int64
+			flags, err = strconv.ParseInt(fields[1], 8, 64)
+			info.Flags = int(flags)
+		}
+		if err != nil {
+			return fmt.Errorf("parse fdinfo of %d err: %s", info.FD, err)
+		}
+	}
+	return nil
+}
+
+// fdKey identifies a descriptor across snapshots, its position and flags
+// changing while it stays open.
+type fdKey struct {
+	fd        int
+	typ, name string
+}
+
+func (fd FDInfo) key() fdKey {
+	return fdKey{fd.FD, fd.Type, fd.Name}
+}
+
+// FDDiff compares two snapshots taken with SnapshotFDs and returns the
+// descriptors closed since snap1 followed by those opened in snap2. A
+// descriptor number reused for another file shows up as both, while a
+// descriptor only moving its position or changing its flags shows up as
+// neither.
+func FDDiff(snap1, snap2 []FDInfo) []FDChange {
+	before := make(map[fdKey]bool, len(snap1))
+	for _, fd := range snap1 {
+		before[fd.key()] = true
+	}
+
+	after := make(map[fdKey]bool, len(snap2))
+	for _, fd := range snap2 {
+		after[fd.key()] = true
+	}
+
+	var changes []FDChange
+	for _, fd := range snap1 {
+		if !after[fd.key()] {
+			changes = append(changes, FDChange{FDInfo: fd})
+		}
+	}
+	for _, fd := range snap2 {
+		if !before[fd.key()] {
+			changes = append(changes, FDChange{FDInfo: fd, Opened: true})
+		}
+	}
+	return changes
+}
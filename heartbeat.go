@@ -0,0 +1,69 @@
+package reenvoy
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// touchHeartbeat updates the modification time of HeartbeatFile, creating it
+// if needed.
+func (r *Process) touchHeartbeat() error {
+	now := time.Now()
+	if err := os.Chtimes(r.HeartbeatFile, now, now); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("touch heartbeat %s err: %s", r.HeartbeatFile, err)
+	}
+
+	f, err := os.OpenFile(r.HeartbeatFile, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("create heartbeat %s err: %s", r.HeartbeatFile, err)
+	}
+	return f.Close()
+}
+
+// heartbeat touches HeartbeatFile every HeartbeatInterval and kills the
+// process once the file has not been touched for HeartbeatTimeout. It
+// returns when the process exits.
+func (r *Process) heartbeat(waitCh <-chan struct{}) {
+	var touch, check <-chan time.Time
+
+	if r.HeartbeatInterval > 0 {
+		t := time.NewTicker(r.HeartbeatInterval)
+		defer t.Stop()
+		touch = t.C
+	}
+
+	if r.HeartbeatTimeout > 0 {
+		t := time.NewTicker(r.HeartbeatTimeout / 4)
+		defer t.Stop()
+		check = t.C
+	}
+
+	for {
+		select {
+		case <-waitCh:
+			return
+		case <-touch:
+			if err := r.touchHeartbeat(); err != nil {
+				log.Printf("[WARN] %s", err)
+			}
+		case <-check:
+			info, err := os.Stat(r.HeartbeatFile)
+			if err != nil {
+				log.Printf("[WARN] heartbeat %s err: %s", r.HeartbeatFile, err)
+				continue
+			}
+
+			if stale := time.Since(info.ModTime()); stale > r.HeartbeatTimeout {
+				log.Printf("[WARN] heartbeat %s is %s old, killing hung process %d", r.HeartbeatFile, stale, r.GetPID())
+				r.RLock()
+				r.signal(os.Kill)
+				r.RUnlock()
+				return
+			}
+		}
+	}
+}
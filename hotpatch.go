@@ -0,0 +1,25 @@
+package reenvoy
+
+import (
+	"errors"
+	"log"
+)
+
+// ApplyHotPatch calls HotPatch with the PID of the running child, patching it
+// without a restart.
+func (r *Process) ApplyHotPatch() error {
+	if r.HotPatch == nil {
+		return errors.New("no HotPatch configured")
+	}
+
+	r.RLock()
+	defer r.RUnlock()
+
+	pid := r.pid()
+	if pid == 0 {
+		return ErrNotRunning
+	}
+
+	log.Printf("[INFO] hot patching process %d", pid)
+	return r.HotPatch(int(pid))
+}
@@ -0,0 +1,27 @@
+//go:build linux
+// +build linux
+
+package reenvoy
+
+import (
+	"fmt"
+	"os"
+)
+
+// MemoryPatch returns a HotPatch overwriting the memory of the process at
+// addr with data through /proc/PID/mem. The caller needs ptrace access to the
+// process, which the parent of a child has under the default Yama policy.
+func MemoryPatch(addr uintptr, data []byte) func(pid int) error {
+	return func(pid int) error {
+		f, err := os.OpenFile(fmt.Sprintf("/proc/%d/mem", pid), os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if _, err := f.WriteAt(data, int64(addr)); err != nil {
+			return fmt.Errorf("patch %d bytes at %#x err: %s", len(data), addr, err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,48 @@
+package reenvoy
+
+import (
+	"os"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var memoryPatchTarget = make([]byte, 5)
+
+func TestMemoryPatch(t *testing.T) {
+	t.Parallel()
+
+	// Patching our own memory exercises the same path as patching a child.
+	// The buffer is a heap copy so the patch doesn't change the read-only
+	// "hello" literal for the whole test binary, and is kept in a package
+	// variable so the compiler doesn't assume its content is unchanged.
+	buf := memoryPatchTarget
+	copy(buf, "hello")
+	patch := MemoryPatch(uintptr(unsafe.Pointer(&buf[0])), []byte("HE"))
+
+	require.Nil(t, patch(os.Getpid()))
+	assert.Equal(t, "HEllo", string(buf))
+}
+
+func TestApplyHotPatch(t *testing.T) {
+	t.Parallel()
+
+	var patched int
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "while true; do sleep 0.2; done"}
+	c.HotPatch = func(pid int) error {
+		patched = pid
+		return nil
+	}
+
+	assert.Equal(t, ErrNotRunning, c.ApplyHotPatch())
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	require.Nil(t, c.ApplyHotPatch())
+	assert.Equal(t, int(c.GetPID()), patched)
+}
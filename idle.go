@@ -0,0 +1,66 @@
+package reenvoy
+
+import (
+	"io"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// idleWatch records when the process last wrote any output.
+type idleWatch struct {
+	// last is in unix nanoseconds and only accessed atomically.
+	last int64
+}
+
+func (w *idleWatch) touch() {
+	atomic.StoreInt64(&w.last, time.Now().UnixNano())
+}
+
+func (w *idleWatch) idle() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&w.last)))
+}
+
+// wrap returns a writer touching w on every write to dst, which may be nil.
+func (w *idleWatch) wrap(dst io.Writer) io.Writer {
+	return writerFunc(func(p []byte) (int, error) {
+		w.touch()
+		if dst == nil {
+			return len(p), nil
+		}
+		return dst.Write(p)
+	})
+}
+
+// watchOutput kills the process once it has not written anything for
+// OutputTimeout, calling OnOutputTimeout first. It returns when the process
+// exits.
+func (r *Process) watchOutput(w *idleWatch, waitCh <-chan struct{}) {
+	timer := time.NewTimer(r.OutputTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-waitCh:
+			return
+		case <-timer.C:
+		}
+
+		idle := w.idle()
+		if idle < r.OutputTimeout {
+			timer.Reset(r.OutputTimeout - idle)
+			continue
+		}
+
+		log.Printf("[WARN] no output for %s, killing hung process %d", idle, r.GetPID())
+		if r.OnOutputTimeout != nil {
+			r.OnOutputTimeout()
+		}
+
+		r.RLock()
+		r.signal(os.Kill)
+		r.RUnlock()
+		return
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package reenvoy
+
+import "os"
+
+// assignJobObject is a no-op outside Windows.
+func assignJobObject(p *os.Process) error {
+	return nil
+}
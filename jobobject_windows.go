@@ -0,0 +1,106 @@
+//go:build windows
+// +build windows
+
+package reenvoy
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	jobObjectExtendedLimitInformationClass = 9
+	jobObjectLimitKillOnJobClose           = 0x2000
+)
+
+var (
+	kernel32                     = syscall.NewLazyDLL("kernel32.dll")
+	procCreateJobObjectW         = kernel32.NewProc("CreateJobObjectW")
+	procSetInformationJobObject  = kernel32.NewProc("SetInformationJobObject")
+	procAssignProcessToJobObject = kernel32.NewProc("AssignProcessToJobObject")
+
+	// job is shared by every child and never closed: Windows closes it when the
+	// supervisor exits, which kills all processes assigned to it.
+	job     syscall.Handle
+	jobErr  error
+	jobOnce sync.Once
+)
+
+type jobObjectBasicLimitInformation struct {
+	PerProcessUserTimeLimit int64
+	PerJobUserTimeLimit     int64
+	LimitFlags              uint32
+	MinimumWorkingSetSize   uintptr
+	MaximumWorkingSetSize   uintptr
+	ActiveProcessLimit      uint32
+	Affinity                uintptr
+	PriorityClass           uint32
+	SchedulingClass         uint32
+}
+
+type ioCounters struct {
+	ReadOperationCount  uint64
+	WriteOperationCount uint64
+	OtherOperationCount uint64
+	ReadTransferCount   uint64
+	WriteTransferCount  uint64
+	OtherTransferCount  uint64
+}
+
+type jobObjectExtendedLimitInformation struct {
+	BasicLimitInformation jobObjectBasicLimitInformation
+	IoInfo                ioCounters
+	ProcessMemoryLimit    uintptr
+	JobMemoryLimit        uintptr
+	PeakProcessMemoryUsed uintptr
+	PeakJobMemoryUsed     uintptr
+}
+
+// createJobObject creates the job object with JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE.
+func createJobObject() (syscall.Handle, error) {
+	h, _, err := procCreateJobObjectW.Call(0, 0)
+	if h == 0 {
+		return 0, err
+	}
+
+	info := jobObjectExtendedLimitInformation{}
+	info.BasicLimitInformation.LimitFlags = jobObjectLimitKillOnJobClose
+
+	ok, _, err := procSetInformationJobObject.Call(
+		h,
+		jobObjectExtendedLimitInformationClass,
+		uintptr(unsafe.Pointer(&info)),
+		unsafe.Sizeof(info),
+	)
+	if ok == 0 {
+		syscall.CloseHandle(syscall.Handle(h))
+		return 0, err
+	}
+
+	return syscall.Handle(h), nil
+}
+
+// assignJobObject puts p in the supervisor's job object so it is killed
+// when the supervisor exits, even if it dies without stopping its children.
+func assignJobObject(p *os.Process) error {
+	jobOnce.Do(func() {
+		job, jobErr = createJobObject()
+	})
+	if jobErr != nil {
+		return jobErr
+	}
+
+	h, err := syscall.OpenProcess(syscall.PROCESS_TERMINATE|0x0100 /* PROCESS_SET_QUOTA */, false, uint32(p.Pid))
+	if err != nil {
+		return err
+	}
+	defer syscall.CloseHandle(h)
+
+	ok, _, err := procAssignProcessToJobObject.Call(uintptr(job), uintptr(h))
+	if ok == 0 {
+		return err
+	}
+	return nil
+}
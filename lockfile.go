@@ -0,0 +1,44 @@
+package reenvoy
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// acquireLock takes an exclusive, non-blocking lock on LockFile, creating
+// the file if necessary. The lock is kept until releaseLock is called.
+func (r *Process) acquireLock() error {
+	if r.LockFile == "" || r.lockFile != nil {
+		return nil
+	}
+
+	f, err := os.OpenFile(r.LockFile, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("open lock file %s err: %s", r.LockFile, err)
+	}
+
+	if err := tryLockFile(f); err != nil {
+		f.Close()
+		if err == ErrAlreadyRunning {
+			return err
+		}
+		return fmt.Errorf("lock %s err: %s", r.LockFile, err)
+	}
+
+	r.lockFile = f
+	return nil
+}
+
+// releaseLock drops the lock taken by acquireLock.
+func (r *Process) releaseLock() {
+	if r.lockFile == nil {
+		return
+	}
+
+	if err := unlockFile(r.lockFile); err != nil {
+		log.Printf("[WARN] failed to unlock %s: %s", r.LockFile, err)
+	}
+	r.lockFile.Close()
+	r.lockFile = nil
+}
@@ -0,0 +1,24 @@
+//go:build aix || darwin || dragonfly || freebsd || hurd || illumos || ios || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd hurd illumos ios linux netbsd openbsd solaris
+
+package reenvoy
+
+import (
+	"os"
+	"syscall"
+)
+
+// tryLockFile takes an exclusive flock on f without blocking, returning
+// ErrAlreadyRunning when it is held elsewhere.
+func tryLockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return ErrAlreadyRunning
+	}
+	return err
+}
+
+// unlockFile drops the flock taken by tryLockFile.
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
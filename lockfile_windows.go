@@ -0,0 +1,47 @@
+//go:build windows
+// +build windows
+
+package reenvoy
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+
+	errorLockViolation syscall.Errno = 33
+)
+
+var (
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+// tryLockFile takes an exclusive lock on the first byte of f with LockFileEx
+// without blocking, returning ErrAlreadyRunning when it is held elsewhere.
+func tryLockFile(f *os.File) error {
+	var ol syscall.Overlapped
+	ok, _, err := procLockFileEx.Call(f.Fd(), lockfileExclusiveLock|lockfileFailImmediately,
+		0, 1, 0, uintptr(unsafe.Pointer(&ol)))
+	if ok != 0 {
+		return nil
+	}
+	if err == errorLockViolation {
+		return ErrAlreadyRunning
+	}
+	return err
+}
+
+// unlockFile drops the lock taken by tryLockFile.
+func unlockFile(f *os.File) error {
+	var ol syscall.Overlapped
+	ok, _, err := procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&ol)))
+	if ok != 0 {
+		return nil
+	}
+	return err
+}
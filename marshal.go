@@ -0,0 +1,141 @@
+package reenvoy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// processJSON has the fields of Process without its methods, so it can be
+// encoded with the default rules from within MarshalJSON.
+type processJSON Process
+
+// MarshalJSON encodes the configuration of the process, leaving out its
+// runtime state, callbacks and standard streams. Signals are encoded by name,
+// e.g. "SIGTERM".
+func (r *Process) MarshalJSON() ([]byte, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	reload, err := signalName(r.ReloadSignal)
+	if err != nil {
+		return nil, err
+	}
+
+	kill, err := signalName(r.KillSignal)
+	if err != nil {
+		return nil, err
+	}
+
+	pressure, err := signalName(r.ResourcePressureSignal)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(struct {
+		*processJSON
+		ReloadSignal           string `json:",omitempty"`
+		KillSignal             string `json:",omitempty"`
+		ResourcePressureSignal string `json:",omitempty"`
+	}{(*processJSON)(r), reload, kill, pressure})
+}
+
+// UnmarshalJSON decodes a configuration encoded by MarshalJSON into the
+// process.
+func (r *Process) UnmarshalJSON(b []byte) error {
+	r.Lock()
+	defer r.Unlock()
+
+	aux := struct {
+		*processJSON
+		ReloadSignal           string
+		KillSignal             string
+		ResourcePressureSignal string
+	}{processJSON: (*processJSON)(r)}
+
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	var err error
+	if r.ReloadSignal, err = ParseSignal(aux.ReloadSignal); err != nil {
+		return err
+	}
+
+	if r.KillSignal, err = ParseSignal(aux.KillSignal); err != nil {
+		return err
+	}
+
+	r.ResourcePressureSignal, err = ParseSignal(aux.ResourcePressureSignal)
+	return err
+}
+
+// MarshalJSON encodes the stage with its signal by name. Condition cannot be
+// encoded and is left out.
+func (s ShutdownStage) MarshalJSON() ([]byte, error) {
+	name, err := signalName(s.Signal)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(struct {
+		Signal       string `json:",omitempty"`
+		WaitDuration time.Duration
+	}{name, s.WaitDuration})
+}
+
+// UnmarshalJSON decodes a stage encoded by MarshalJSON.
+func (s *ShutdownStage) UnmarshalJSON(b []byte) error {
+	var aux struct {
+		Signal       string
+		WaitDuration time.Duration
+	}
+
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	sig, err := ParseSignal(aux.Signal)
+	if err != nil {
+		return err
+	}
+
+	*s = ShutdownStage{Signal: sig, WaitDuration: aux.WaitDuration}
+	return nil
+}
+
+// signalName returns the name of s, e.g. "SIGTERM", or an empty name for a
+// nil signal.
+func signalName(s os.Signal) (string, error) {
+	if s == nil {
+		return "", nil
+	}
+
+	for name, sig := range signals {
+		if sig == s {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("unknown signal %q", s)
+}
+
+// ParseSignal returns the signal with the given name, with or without the SIG
+// prefix. An empty name is a nil signal.
+func ParseSignal(name string) (os.Signal, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+
+	sig, ok := signals[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown signal %q", name)
+	}
+	return sig, nil
+}
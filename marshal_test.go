@@ -0,0 +1,59 @@
+package reenvoy
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	p := &Process{
+		Env:          []string{"A=1"},
+		Labels:       map[string]string{"tier": "frontend"},
+		ConfigPath:   "/etc/envoy.yaml",
+		ReloadSignal: os.Interrupt,
+		KillSignal:   syscall.SIGTERM,
+		KillTimeout:  2 * time.Second,
+		ShutdownStages: []ShutdownStage{
+			{Signal: syscall.SIGUSR1, WaitDuration: time.Second, Condition: func() bool { return true }},
+		},
+		Stdout:   os.Stdout,
+		HotPatch: func(int) error { return nil },
+	}
+
+	b, err := json.Marshal(p)
+	require.Nil(t, err)
+	assert.Contains(t, string(b), `"KillSignal":"SIGTERM"`)
+	assert.NotContains(t, string(b), `"Stdout":`)
+
+	var q Process
+	require.Nil(t, json.Unmarshal(b, &q))
+	assert.Equal(t, p.Env, q.Env)
+	assert.Equal(t, p.Labels, q.Labels)
+	assert.Equal(t, p.ConfigPath, q.ConfigPath)
+	assert.Equal(t, p.KillTimeout, q.KillTimeout)
+	assert.Equal(t, os.Signal(syscall.SIGINT), q.ReloadSignal)
+	assert.Equal(t, os.Signal(syscall.SIGTERM), q.KillSignal)
+	require.Len(t, q.ShutdownStages, 1)
+	assert.Equal(t, os.Signal(syscall.SIGUSR1), q.ShutdownStages[0].Signal)
+	assert.Equal(t, time.Second, q.ShutdownStages[0].WaitDuration)
+	assert.Nil(t, q.ShutdownStages[0].Condition)
+}
+
+func TestUnmarshalJSON_signalName(t *testing.T) {
+	t.Parallel()
+
+	var p Process
+	require.Nil(t, json.NewDecoder(strings.NewReader(`{"KillSignal":"term"}`)).Decode(&p))
+	assert.Equal(t, os.Signal(syscall.SIGTERM), p.KillSignal)
+
+	assert.NotNil(t, json.Unmarshal([]byte(`{"KillSignal":"SIGNOPE"}`), &p))
+}
@@ -0,0 +1,129 @@
+package reenvoy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// memoryLeakSampleInterval is how often the RSS of the process is sampled
+	// by MemoryLeakDetect.
+	memoryLeakSampleInterval = time.Minute
+
+	// readRSS returns the resident memory of pid in bytes.
+	readRSS = procRSS
+
+	// defaultMemoryLeakWindow is the MemoryLeakWindow used when none is set.
+	defaultMemoryLeakWindow = time.Hour
+)
+
+// procRSS reads the resident memory of pid from /proc/PID/status.
+func procRSS(pid int) (int64, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// "VmRSS:	   10240 kB"
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || fields[0] != "VmRSS:" {
+			continue
+		}
+
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("parse VmRSS of %d err: %s", pid, err)
+		}
+		return kb * 1024, nil
+	}
+	return 0, fmt.Errorf("no VmRSS for process %d", pid)
+}
+
+// rssSample is the resident memory of the process at a point in time.
+type rssSample struct {
+	at  time.Time
+	rss int64
+}
+
+// rssGrowthRate fits a line through samples by least squares and returns its
+// slope, in bytes per minute.
+func rssGrowthRate(samples []rssSample) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+
+	n := float64(len(samples))
+	var sumX, sumY, sumXY, sumXX float64
+	for _, s := range samples {
+		x := s.at.Sub(samples[0].at).Minutes()
+		y := float64(s.rss)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	d := n*sumXX - sumX*sumX
+	if d == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / d
+}
+
+// watchMemoryLeak samples the RSS of pid with read every interval and
+// reports a leak once the growth rate over the last MemoryLeakWindow exceeds
+// MemoryLeakThreshold. After a report a whole window is sampled again before
+// the next one. It returns when the process exits.
+func (r *Process) watchMemoryLeak(read func(int) (int64, error), interval time.Duration, pid int, waitCh <-chan struct{}) {
+	window := r.MemoryLeakWindow
+	if window <= 0 {
+		window = defaultMemoryLeakWindow
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var samples []rssSample
+	for {
+		select {
+		case <-waitCh:
+			return
+		case <-ticker.C:
+		}
+
+		rss, err := read(pid)
+		if err != nil {
+			log.Printf("[WARN] unable to read memory of process %d, not watching it anymore: %s", pid, err)
+			return
+		}
+
+		now := time.Now()
+		samples = append(samples, rssSample{now, rss})
+		if now.Sub(samples[0].at) < window {
+			continue
+		}
+		for now.Sub(samples[0].at) > window {
+			samples = samples[1:]
+		}
+
+		rate := int64(rssGrowthRate(samples))
+		if rate <= 0 || rate <= r.MemoryLeakThreshold {
+			continue
+		}
+
+		log.Printf("[WARN] memory of process %d grew by %d bytes per minute over %s, it may be leaking",
+			pid, rate, window)
+		if r.OnMemoryLeak != nil {
+			r.OnMemoryLeak(rate)
+		}
+		samples = nil
+	}
+}
@@ -0,0 +1,63 @@
+package reenvoy
+
+import (
+	"os"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRSSGrowthRate(t *testing.T) {
+	t.Parallel()
+
+	start := time.Unix(0, 0)
+	samples := []rssSample{
+		{start, 1000},
+		{start.Add(time.Minute), 1600},
+		{start.Add(2 * time.Minute), 1900},
+		{start.Add(3 * time.Minute), 2500},
+	}
+	assert.InDelta(t, 480, rssGrowthRate(samples), 0.001)
+	assert.Equal(t, float64(0), rssGrowthRate(samples[:1]))
+
+	if runtime.GOOS == "linux" {
+		rss, err := procRSS(os.Getpid())
+		require.Nil(t, err)
+		assert.True(t, rss > 0)
+	}
+}
+
+func TestMemoryLeakDetect(t *testing.T) {
+	defer func(interval time.Duration) { memoryLeakSampleInterval = interval }(memoryLeakSampleInterval)
+	memoryLeakSampleInterval = 10 * time.Millisecond
+
+	var rss int64
+	defer func(fn func(int) (int64, error)) { readRSS = fn }(readRSS)
+	readRSS = func(int) (int64, error) {
+		return atomic.AddInt64(&rss, 1<<20), nil
+	}
+
+	leaks := make(chan int64, 10)
+	c := testProcess(t)
+	c.Command = "sleep"
+	c.Args = []string{"5"}
+	c.MemoryLeakDetect = true
+	c.MemoryLeakWindow = 50 * time.Millisecond
+	c.MemoryLeakThreshold = 1 << 20
+	c.OnMemoryLeak = func(rate int64) { leaks <- rate }
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	select {
+	case rate := <-leaks:
+		// 1MiB every 10ms is about 6000MiB per minute.
+		assert.True(t, rate > 1000<<20, "rate %d", rate)
+	case <-time.After(2 * time.Second):
+		t.Fatal("memory leak should have been reported")
+	}
+}
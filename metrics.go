@@ -0,0 +1,91 @@
+package reenvoy
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// maxMetricSamples is the number of most recent values kept per metric.
+const maxMetricSamples = 1024
+
+// metricsParser extracts the named groups of a pattern from output lines.
+type metricsParser struct {
+	sync.Mutex
+	pattern *regexp.Regexp
+	values  map[string][]float64
+}
+
+// prepareMetrics compiles MetricsPattern the first time the process starts,
+// keeping the values parsed so far across restarts.
+func (r *Process) prepareMetrics() error {
+	if r.MetricsPattern == "" || (r.metrics != nil && r.metrics.pattern.String() == r.MetricsPattern) {
+		return nil
+	}
+
+	pattern, err := regexp.Compile(r.MetricsPattern)
+	if err != nil {
+		return fmt.Errorf("metrics pattern err: %s", err)
+	}
+
+	r.metrics = &metricsParser{
+		pattern: pattern,
+		values:  map[string][]float64{},
+	}
+	return nil
+}
+
+// ParsedMetrics returns the values extracted from the output with
+// MetricsPattern, keyed by group name, oldest first. Only the most recent
+// 1024 values of each metric are kept.
+func (r *Process) ParsedMetrics() map[string][]float64 {
+	r.RLock()
+	metrics := r.metrics
+	r.RUnlock()
+
+	m := map[string][]float64{}
+	if metrics == nil {
+		return m
+	}
+
+	metrics.Lock()
+	defer metrics.Unlock()
+
+	for name, values := range metrics.values {
+		m[name] = append([]float64(nil), values...)
+	}
+	return m
+}
+
+// parse records the named groups of every match of the pattern in line.
+// Groups that are not numbers are ignored.
+func (m *metricsParser) parse(line []byte) {
+	matches := m.pattern.FindAllSubmatch(line, -1)
+	if len(matches) == 0 {
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	names := m.pattern.SubexpNames()
+	for _, match := range matches {
+		for i, name := range names {
+			if name == "" || match[i] == nil {
+				continue
+			}
+
+			v, err := strconv.ParseFloat(string(match[i]), 64)
+			if err != nil {
+				continue
+			}
+
+			values := append(m.values[name], v)
+			if len(values) > maxMetricSamples {
+				values = values[len(values)-maxMetricSamples:]
+			}
+			m.values[name] = values
+		}
+	}
+}
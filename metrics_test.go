@@ -0,0 +1,100 @@
+package reenvoy
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsPattern(t *testing.T) {
+	t.Parallel()
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "echo latency=12ms; echo latency=7ms code=200 >&2; echo latency=slow"}
+	c.MetricsPattern = `latency=(?P<latency>\d+)ms`
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	select {
+	case <-c.ExitCh():
+	case <-time.After(fileWaitSleepDelay):
+		t.Fatal("process should have exited")
+	}
+
+	metrics := c.ParsedMetrics()
+	require.Len(t, metrics["latency"], 2)
+	assert.ElementsMatch(t, []float64{12, 7}, metrics["latency"])
+}
+
+func TestMetricsPattern_lineSeparator(t *testing.T) {
+	t.Parallel()
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", `printf 'latency=12ms\rlatency=7ms\r'`}
+	c.MetricsPattern = `^latency=(?P<latency>\d+)ms$`
+	c.LineSeparator = '\r'
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	select {
+	case <-c.ExitCh():
+	case <-time.After(fileWaitSleepDelay):
+		t.Fatal("process should have exited")
+	}
+
+	assert.Equal(t, []float64{12, 7}, c.ParsedMetrics()["latency"])
+}
+
+func TestMetricsPattern_nulSeparator(t *testing.T) {
+	t.Parallel()
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", `printf 'latency=12ms\0latency=7ms\0'`}
+	c.MetricsPattern = `^latency=(?P<latency>\d+)ms$`
+	c.LineSeparator = '\x00'
+	c.LineSeparatorSet = true
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	select {
+	case <-c.ExitCh():
+	case <-time.After(fileWaitSleepDelay):
+		t.Fatal("process should have exited")
+	}
+
+	assert.Equal(t, []float64{12, 7}, c.ParsedMetrics()["latency"])
+}
+
+func TestMetricsPattern_invalid(t *testing.T) {
+	t.Parallel()
+
+	c := testProcess(t)
+	c.MetricsPattern = `(?P<broken`
+	assert.NotNil(t, c.Start())
+}
+
+func TestMetricsParser_samples(t *testing.T) {
+	t.Parallel()
+
+	c := testProcess(t)
+	c.MetricsPattern = `n=(?P<n>\d+)`
+	require.Nil(t, c.prepareMetrics())
+
+	w := newLineWriter('\n', c.metrics.parse)
+	for i := 0; i < maxMetricSamples+10; i++ {
+		fmt.Fprintf(w, "n=%d\n", i)
+	}
+
+	values := c.ParsedMetrics()["n"]
+	require.Len(t, values, maxMetricSamples)
+	assert.Equal(t, float64(10), values[0])
+}
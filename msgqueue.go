@@ -0,0 +1,139 @@
+package reenvoy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// msgToChild is the named pipe in MessageQueueDir carrying messages from
+	// the supervisor to the child.
+	msgToChild = "to-child"
+
+	// msgFromChild is the named pipe in MessageQueueDir carrying messages
+	// from the child to the supervisor.
+	msgFromChild = "from-child"
+)
+
+// messageQueue holds the supervisor ends of the named pipes in
+// MessageQueueDir. Messages are newline delimited.
+type messageQueue struct {
+	to   *os.File
+	from *os.File
+
+	wmu    sync.Mutex
+	rmu    sync.Mutex
+	reader *bufio.Reader
+}
+
+// prepareMessageQueue creates the named pipes in MessageQueueDir and opens
+// the supervisor ends of them.
+func (r *Process) prepareMessageQueue() error {
+	if r.MessageQueueDir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(r.MessageQueueDir, 0700); err != nil {
+		return fmt.Errorf("create message queue dir %s err: %s", r.MessageQueueDir, err)
+	}
+
+	to, err := openFIFO(filepath.Join(r.MessageQueueDir, msgToChild))
+	if err != nil {
+		return fmt.Errorf("message queue err: %s", err)
+	}
+
+	from, err := openFIFO(filepath.Join(r.MessageQueueDir, msgFromChild))
+	if err != nil {
+		to.Close()
+		return fmt.Errorf("message queue err: %s", err)
+	}
+
+	r.msgQueue = &messageQueue{
+		to:     to,
+		from:   from,
+		reader: bufio.NewReader(from),
+	}
+	return nil
+}
+
+// closeMessageQueue closes the supervisor ends of the named pipes, which
+// unblocks any pending ReceiveMessage.
+func (r *Process) closeMessageQueue() {
+	if r.msgQueue == nil {
+		return
+	}
+
+	for _, f := range []*os.File{r.msgQueue.to, r.msgQueue.from} {
+		if err := f.Close(); err != nil {
+			log.Printf("[WARN] failed to close message queue %s: %s", f.Name(), err)
+		}
+	}
+	r.msgQueue = nil
+}
+
+// messageQueueEnv returns env extended with the paths of the named pipes the
+// child reads messages from (REENVOY_MSG_IN) and writes messages to
+// (REENVOY_MSG_OUT).
+func (r *Process) messageQueueEnv(env []string) []string {
+	if r.MessageQueueDir == "" {
+		return env
+	}
+
+	if env == nil {
+		env = os.Environ()
+	}
+
+	return append(env[:len(env):len(env)],
+		"REENVOY_MSG_IN="+filepath.Join(r.MessageQueueDir, msgToChild),
+		"REENVOY_MSG_OUT="+filepath.Join(r.MessageQueueDir, msgFromChild),
+	)
+}
+
+// SendMessage writes msg, which must not contain a newline, to the child.
+// Messages are buffered by the pipe until the child reads them.
+func (r *Process) SendMessage(msg []byte) error {
+	if bytes.IndexByte(msg, '\n') >= 0 {
+		return fmt.Errorf("message must not contain a newline")
+	}
+
+	r.RLock()
+	q := r.msgQueue
+	r.RUnlock()
+
+	if q == nil {
+		return ErrNoMessageQueue
+	}
+
+	q.wmu.Lock()
+	defer q.wmu.Unlock()
+
+	_, err := q.to.Write(append(msg[:len(msg):len(msg)], '\n'))
+	return err
+}
+
+// ReceiveMessage blocks until the child writes a message and returns it
+// without the trailing newline. It returns an error once the process is
+// killed or stopped.
+func (r *Process) ReceiveMessage() ([]byte, error) {
+	r.RLock()
+	q := r.msgQueue
+	r.RUnlock()
+
+	if q == nil {
+		return nil, ErrNoMessageQueue
+	}
+
+	q.rmu.Lock()
+	defer q.rmu.Unlock()
+
+	msg, err := q.reader.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	return msg[:len(msg)-1], nil
+}
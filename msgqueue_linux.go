@@ -0,0 +1,25 @@
+//go:build linux
+// +build linux
+
+package reenvoy
+
+import (
+	"os"
+	"syscall"
+)
+
+// openFIFO creates the named pipe at path, replacing anything left there, and
+// opens it for reading and writing. Holding both ends keeps the open from
+// blocking until the child shows up and keeps reads from seeing EOF when the
+// child closes its end.
+func openFIFO(path string) (*os.File, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		return nil, &os.PathError{Op: "mkfifo", Path: path, Err: err}
+	}
+
+	return os.OpenFile(path, os.O_RDWR, 0)
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package reenvoy
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// openFIFO is only supported on Linux.
+func openFIFO(path string) (*os.File, error) {
+	return nil, fmt.Errorf("message queue is not supported on %s", runtime.GOOS)
+}
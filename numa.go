@@ -0,0 +1,49 @@
+package reenvoy
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// numaNodesGlob matches the sysfs directories of the host NUMA nodes.
+var numaNodesGlob = "/sys/devices/system/node/node[0-9]*"
+
+// NUMANodes returns the NUMA nodes available on the host, in ascending order.
+// It returns nil when the host does not expose a NUMA topology.
+func NUMANodes() []int {
+	matches, _ := filepath.Glob(numaNodesGlob)
+
+	var nodes []int
+	for _, m := range matches {
+		n, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(m), "node"))
+		if err == nil {
+			nodes = append(nodes, n)
+		}
+	}
+
+	sort.Ints(nodes)
+	return nodes
+}
+
+// numaCommand wraps command in numactl so it only runs on the CPUs and
+// allocates from the memory of NUMANode.
+func (r *Process) numaCommand(command string, args []string) (string, []string, error) {
+	found := false
+	for _, n := range NUMANodes() {
+		found = found || n == r.NUMANode
+	}
+	if !found {
+		return "", nil, fmt.Errorf("numa node %d is not available on this host", r.NUMANode)
+	}
+
+	node := strconv.Itoa(r.NUMANode)
+	return "numactl", append([]string{
+		"--cpunodebind=" + node,
+		"--membind=" + node,
+		"--",
+		command,
+	}, args...), nil
+}
@@ -0,0 +1,38 @@
+package reenvoy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNUMANodes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, node := range []string{"node1", "node0", "node10", "possible"} {
+		require.Nil(t, os.Mkdir(filepath.Join(dir, node), 0755))
+	}
+
+	defer func(glob string) { numaNodesGlob = glob }(numaNodesGlob)
+	numaNodesGlob = filepath.Join(dir, "node[0-9]*")
+
+	assert.Equal(t, []int{0, 1, 10}, NUMANodes())
+
+	c := testProcess(t)
+	c.NUMANode = 1
+
+	command, args, err := c.numaCommand("envoy", []string{"-c", "envoy.yaml"})
+	require.Nil(t, err)
+	assert.Equal(t, "numactl", command)
+	assert.Equal(t, []string{"--cpunodebind=1", "--membind=1", "--", "envoy", "-c", "envoy.yaml"}, args)
+
+	c.NUMANode = 2
+	_, _, err = c.numaCommand("envoy", nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,87 @@
+package reenvoy
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// maxLineLength bounds the partial line buffered by lineWriter; longer lines
+// are cut at that length.
+const maxLineLength = 64 * 1024
+
+// outputs returns the writers for the standard output and error of the
+// child, wrapped by the output features enabled on the process.
+func (r *Process) outputs() (io.Writer, io.Writer) {
+	stdout := encodeWriter(r.Stdout, r.StdoutEncoding)
+	stderr := encodeWriter(r.StdErr, r.StderrEncoding)
+
+	if r.outputLimit != nil {
+		stdout = r.outputLimit.wrap(stdout)
+		stderr = r.outputLimit.wrap(stderr)
+	}
+
+	if r.metrics != nil {
+		sep := r.lineSeparator()
+		stdout = teeWriter(stdout, newLineWriter(sep, r.metrics.parse))
+		stderr = teeWriter(stderr, newLineWriter(sep, r.metrics.parse))
+	}
+
+	if r.idle != nil {
+		stdout = r.idle.wrap(stdout)
+		stderr = r.idle.wrap(stderr)
+	}
+
+	return stdout, stderr
+}
+
+// lineSeparator returns the byte ending the lines of output.
+func (r *Process) lineSeparator() byte {
+	if r.LineSeparator == 0 && !r.LineSeparatorSet {
+		return '\n'
+	}
+	return r.LineSeparator
+}
+
+// teeWriter duplicates the writes to w, which may be nil, into extra.
+func teeWriter(w, extra io.Writer) io.Writer {
+	if w == nil {
+		return extra
+	}
+	return io.MultiWriter(w, extra)
+}
+
+// lineWriter calls fn with every complete line written to it, without the
+// trailing separator.
+type lineWriter struct {
+	sync.Mutex
+	buf []byte
+	sep byte
+	fn  func(line []byte)
+}
+
+func newLineWriter(sep byte, fn func(line []byte)) *lineWriter {
+	return &lineWriter{sep: sep, fn: fn}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, w.sep)
+		if i < 0 {
+			break
+		}
+		w.fn(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+
+	if len(w.buf) >= maxLineLength {
+		w.fn(w.buf)
+		w.buf = nil
+	}
+
+	return len(p), nil
+}
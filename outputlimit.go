@@ -0,0 +1,128 @@
+package reenvoy
+
+import (
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// outputLimiter drops the output of the child above a number of bytes per
+// minute, over a sliding window of one second buckets.
+type outputLimiter struct {
+	sync.Mutex
+
+	limit   int64
+	buckets [60]int64
+	last    int64
+
+	dropping bool
+	dropped  int64
+	total    int64
+}
+
+// DroppedOutputBytes returns the number of output bytes dropped so far
+// because of MaxOutputBytesPerMinute.
+func (r *Process) DroppedOutputBytes() int64 {
+	r.RLock()
+	l := r.outputLimit
+	r.RUnlock()
+
+	if l == nil {
+		return 0
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	return l.total
+}
+
+// prepareOutputLimit sets up the output limiter on first start, keeping its
+// window and counters across restarts.
+func (r *Process) prepareOutputLimit() {
+	if r.MaxOutputBytesPerMinute <= 0 {
+		r.outputLimit = nil
+		return
+	}
+
+	if r.outputLimit == nil {
+		r.outputLimit = &outputLimiter{}
+	}
+
+	r.outputLimit.Lock()
+	r.outputLimit.limit = r.MaxOutputBytesPerMinute
+	r.outputLimit.Unlock()
+}
+
+// wrap returns a writer passing to dst, which may be nil, what fits in the
+// limit and dropping the rest. Writes never fail because of the limit, so the
+// child is not disturbed.
+func (l *outputLimiter) wrap(dst io.Writer) io.Writer {
+	if dst == nil {
+		return nil
+	}
+
+	return writerFunc(func(p []byte) (int, error) {
+		n := l.allow(int64(len(p)))
+		if n == 0 {
+			return len(p), nil
+		}
+
+		if _, err := dst.Write(p[:n]); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	})
+}
+
+// allow returns how many of n bytes fit in the limit and accounts for them.
+func (l *outputLimiter) allow(n int64) int64 {
+	l.Lock()
+	defer l.Unlock()
+
+	now := time.Now().Unix()
+	l.advance(now)
+
+	var used int64
+	for _, b := range l.buckets {
+		used += b
+	}
+
+	allowed := l.limit - used
+	if allowed < 0 {
+		allowed = 0
+	}
+	if allowed > n {
+		allowed = n
+	}
+
+	if drop := n - allowed; drop > 0 {
+		if !l.dropping {
+			log.Printf("[WARN] output above %d bytes per minute, dropping", l.limit)
+			l.dropping = true
+		}
+		l.dropped += drop
+		l.total += drop
+	} else if l.dropping {
+		log.Printf("[WARN] output back under %d bytes per minute, dropped %d bytes", l.limit, l.dropped)
+		l.dropping = false
+		l.dropped = 0
+	}
+
+	l.buckets[now%int64(len(l.buckets))] += allowed
+	return allowed
+}
+
+// advance clears the buckets that fell out of the window since the last
+// write.
+func (l *outputLimiter) advance(now int64) {
+	size := int64(len(l.buckets))
+	if now-l.last >= size {
+		l.buckets = [60]int64{}
+	} else {
+		for t := l.last + 1; t <= now; t++ {
+			l.buckets[t%size] = 0
+		}
+	}
+	l.last = now
+}
@@ -0,0 +1,54 @@
+package reenvoy
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputLimiter(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	l := &outputLimiter{limit: 10}
+	w := l.wrap(&out)
+
+	n, err := w.Write([]byte("0123456"))
+	require.Nil(t, err)
+	assert.Equal(t, 7, n)
+
+	n, err = w.Write([]byte("789abcdef"))
+	require.Nil(t, err)
+	assert.Equal(t, 9, n)
+
+	assert.Equal(t, "0123456789", out.String())
+	assert.Equal(t, int64(6), l.total)
+
+	// Once the window moved past the writes, output flows again.
+	l.advance(l.last + 60)
+	w.Write([]byte("xyz"))
+	assert.Equal(t, "0123456789xyz", out.String())
+}
+
+func TestMaxOutputBytesPerMinute(t *testing.T) {
+	t.Parallel()
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "printf '%0100d' 0; printf '%0100d' 0 >&2"}
+	c.Stdout = stdout
+	c.StdErr = stderr
+	c.MaxOutputBytesPerMinute = 150
+
+	require.Nil(t, c.Start())
+	<-c.ExitCh()
+
+	// Both streams share the budget, whichever is copied first using more
+	// of it.
+	assert.Equal(t, strings.Repeat("0", 150), stdout.String()+stderr.String())
+	assert.Equal(t, int64(50), c.DroppedOutputBytes())
+}
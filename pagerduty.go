@@ -0,0 +1,209 @@
+package reenvoy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// pagerDutyEventsURL is the endpoint of the PagerDuty Events API v2.
+	pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+	pagerDutyClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// pagerDutyAlert tracks the PagerDuty incident of a process. downTimer
+// triggers the incident once the process stayed down for
+// PagerDutyAlertAfter, upTimer resolves it once the process stayed up for
+// PagerDutyRecoveryWindow. dedupKey identifies the open incident, if any, and
+// waitCh the current run, whose successor can't be reported down by the late
+// exit of a child replaced by a restart.
+type pagerDutyAlert struct {
+	sync.Mutex
+	downTimer *time.Timer
+	upTimer   *time.Timer
+	dedupKey  string
+	waitCh    chan struct{}
+}
+
+// pagerDutyEvent is an event of the PagerDuty Events API v2.
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+// pagerDutyDown arms the trigger of an incident, as the run of waitCh ended,
+// unless a newer run started meanwhile.
+func (r *Process) pagerDutyDown(waitCh chan struct{}) {
+	if r.PagerDutyKey == "" || r.PagerDutyAlertAfter <= 0 {
+		return
+	}
+
+	a := &r.pagerDuty
+	a.Lock()
+	defer a.Unlock()
+
+	if a.waitCh != waitCh {
+		return
+	}
+
+	if a.upTimer != nil {
+		a.upTimer.Stop()
+		a.upTimer = nil
+	}
+
+	if a.downTimer == nil && a.dedupKey == "" {
+		a.downTimer = time.AfterFunc(r.PagerDutyAlertAfter, r.pagerDutyTrigger)
+	}
+}
+
+// pagerDutyUp disarms the trigger of an incident, or arms its resolution
+// when one is open, as the run of waitCh started.
+func (r *Process) pagerDutyUp(waitCh chan struct{}) {
+	if r.PagerDutyKey == "" || r.PagerDutyAlertAfter <= 0 {
+		return
+	}
+
+	a := &r.pagerDuty
+	a.Lock()
+	defer a.Unlock()
+
+	a.waitCh = waitCh
+
+	if a.downTimer != nil {
+		a.downTimer.Stop()
+		a.downTimer = nil
+	}
+
+	if a.dedupKey != "" && a.upTimer == nil {
+		a.upTimer = time.AfterFunc(r.PagerDutyRecoveryWindow, r.pagerDutyResolve)
+	}
+}
+
+// haltPagerDuty disarms the trigger of an incident and ignores the exit of
+// the current child, as the process was stopped or killed on purpose.
+func (r *Process) haltPagerDuty() {
+	a := &r.pagerDuty
+	a.Lock()
+	defer a.Unlock()
+
+	a.waitCh = nil
+	if a.downTimer != nil {
+		a.downTimer.Stop()
+		a.downTimer = nil
+	}
+}
+
+// pagerDutyTrigger opens an incident, unless the process was stopped on
+// purpose.
+func (r *Process) pagerDutyTrigger() {
+	r.stopLock.RLock()
+	stopped := r.stopped
+	r.stopLock.RUnlock()
+	restarts := r.RestartCount()
+
+	a := &r.pagerDuty
+	a.Lock()
+	if a.downTimer == nil || stopped {
+		// The process came back up, or is meant to be down.
+		a.downTimer = nil
+		a.Unlock()
+		return
+	}
+
+	host, _ := os.Hostname()
+	a.downTimer = nil
+	a.dedupKey = fmt.Sprintf("reenvoy-%s-%d", host, time.Now().UnixNano())
+	event := pagerDutyEvent{
+		RoutingKey:  r.PagerDutyKey,
+		EventAction: "trigger",
+		DedupKey:    a.dedupKey,
+		Payload: &pagerDutyPayload{
+			Summary:  fmt.Sprintf("%s has not been running for %s", r.commandName(), r.PagerDutyAlertAfter),
+			Source:   host,
+			Severity: "critical",
+			CustomDetails: map[string]string{
+				"command":  r.commandName(),
+				"restarts": strconv.Itoa(restarts),
+			},
+		},
+	}
+	a.Unlock()
+
+	log.Printf("[WARN] process down for %s, triggering PagerDuty incident", r.PagerDutyAlertAfter)
+	if err := sendPagerDutyEvent(event); err != nil {
+		log.Printf("[WARN] failed to trigger PagerDuty incident: %s", err)
+	}
+}
+
+// pagerDutyResolve resolves the open incident.
+func (r *Process) pagerDutyResolve() {
+	a := &r.pagerDuty
+	a.Lock()
+	if a.upTimer == nil {
+		// The process went down again.
+		a.Unlock()
+		return
+	}
+
+	event := pagerDutyEvent{
+		RoutingKey:  r.PagerDutyKey,
+		EventAction: "resolve",
+		DedupKey:    a.dedupKey,
+	}
+	a.upTimer = nil
+	a.dedupKey = ""
+	a.Unlock()
+
+	log.Printf("[INFO] process recovered, resolving PagerDuty incident")
+	if err := sendPagerDutyEvent(event); err != nil {
+		log.Printf("[WARN] failed to resolve PagerDuty incident: %s", err)
+	}
+}
+
+// commandName returns the command run by the process, for humans.
+func (r *Process) commandName() string {
+	switch {
+	case r.Command != "":
+		return r.Command
+	case r.PodmanContainer != "":
+		return r.PodmanContainer
+	case r.WASMFile != "":
+		return r.WASMFile
+	}
+	return "envoy"
+}
+
+func sendPagerDutyEvent(event pagerDutyEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	resp, err := pagerDutyClient.Post(pagerDutyEventsURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package reenvoy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPagerDuty(t *testing.T) {
+	events := make(chan pagerDutyEvent, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var event pagerDutyEvent
+		if err := json.NewDecoder(req.Body).Decode(&event); err == nil {
+			events <- event
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	defer func(url string) { pagerDutyEventsURL = url }(pagerDutyEventsURL)
+	pagerDutyEventsURL = ts.URL
+
+	c := testProcess(t)
+	c.Command = "true"
+	c.Args = nil
+	c.ReloadSignal = nil
+	c.PagerDutyKey = "routing-key"
+	c.PagerDutyAlertAfter = 50 * time.Millisecond
+	c.PagerDutyRecoveryWindow = 50 * time.Millisecond
+
+	// The process exits at once and stays down.
+	require.Nil(t, c.Start())
+
+	var trigger pagerDutyEvent
+	select {
+	case trigger = <-events:
+	case <-time.After(2 * time.Second):
+		t.Fatal("an incident should have been triggered")
+	}
+	assert.Equal(t, "routing-key", trigger.RoutingKey)
+	assert.Equal(t, "trigger", trigger.EventAction)
+	require.NotNil(t, trigger.Payload)
+	assert.Equal(t, "true", trigger.Payload.CustomDetails["command"])
+
+	// It recovers and stays up for the recovery window.
+	c.Command = "sleep"
+	c.Args = []string{"5"}
+	require.Nil(t, c.Restart())
+
+	select {
+	case resolve := <-events:
+		assert.Equal(t, "resolve", resolve.EventAction)
+		assert.Equal(t, trigger.DedupKey, resolve.DedupKey)
+	case <-time.After(2 * time.Second):
+		t.Fatal("the incident should have been resolved")
+	}
+
+	// Stopping the process on purpose triggers nothing.
+	c.Stop()
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected event %+v", event)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestPagerDuty_restart(t *testing.T) {
+	events := make(chan pagerDutyEvent, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var event pagerDutyEvent
+		if err := json.NewDecoder(req.Body).Decode(&event); err == nil {
+			events <- event
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	defer func(url string) { pagerDutyEventsURL = url }(pagerDutyEventsURL)
+	pagerDutyEventsURL = ts.URL
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "while true; do sleep 0.2; done"}
+	c.ReloadSignal = nil
+	c.PagerDutyKey = "routing-key"
+	c.PagerDutyAlertAfter = 50 * time.Millisecond
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	// The exit of a replaced child must not report its successor down.
+	for i := 0; i < 20; i++ {
+		time.Sleep(20 * time.Millisecond)
+		require.Nil(t, c.Restart())
+	}
+
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected event %+v", event)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
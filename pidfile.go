@@ -0,0 +1,51 @@
+package reenvoy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// writePIDFile writes the PID of the child to PIDFile. The PID is written to a
+// temporary file renamed over PIDFile, so readers never see a partial file.
+func (r *Process) writePIDFile() error {
+	dir := filepath.Dir(r.PIDFile)
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("pid file directory %s err: %s", dir, err)
+	}
+
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(r.PIDFile))
+	if err != nil {
+		return fmt.Errorf("pid file %s err: %s", r.PIDFile, err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(strconv.Itoa(int(r.pid())) + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), r.PIDFile)
+	}
+	if err != nil {
+		return fmt.Errorf("pid file %s err: %s", r.PIDFile, err)
+	}
+	return nil
+}
+
+// removePIDFile removes PIDFile, if any.
+func (r *Process) removePIDFile() {
+	if r.PIDFile == "" {
+		return
+	}
+
+	if err := os.Remove(r.PIDFile); err != nil && !os.IsNotExist(err) {
+		log.Printf("[WARN] failed to remove pid file %s: %s", r.PIDFile, err)
+	}
+}
@@ -0,0 +1,135 @@
+package reenvoy
+
+import (
+	"log"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"sync"
+)
+
+// ExitStatus describes how a process exited.
+type ExitStatus struct {
+	// Code is the exit code of the process, -1 if it was killed by a signal.
+	Code int
+}
+
+// LifecyclePlugin is notified of the lifecycle events of a Process. The
+// methods are called from another goroutine, in the order the events
+// happened, and must not block for long.
+type LifecyclePlugin interface {
+	// OnStart is called once the process started.
+	OnStart(pid int)
+
+	// OnStop is called once the process exited.
+	OnStop(pid int, status ExitStatus)
+
+	// OnRestart is called once Restart replaced the process by a new one.
+	OnRestart(oldPID, newPID int)
+
+	// OnSignal is called when a signal is sent to the process with Signal or
+	// UrgentSignal.
+	OnSignal(sig os.Signal)
+}
+
+// pluginQueue orders the notifications of the plugins: each one waits for
+// the previous to be delivered.
+type pluginQueue struct {
+	sync.Mutex
+	last chan struct{}
+}
+
+// notify calls fn with every plugin in LifecyclePlugins from a new goroutine,
+// after the previous notifications were delivered.
+func (r *Process) notify(fn func(LifecyclePlugin)) {
+	if len(r.LifecyclePlugins) == 0 {
+		return
+	}
+
+	q := &r.pluginQueue
+	q.Lock()
+	prev, done := q.last, make(chan struct{})
+	q.last = done
+	q.Unlock()
+
+	plugins := r.LifecyclePlugins
+	go func() {
+		defer close(done)
+		if prev != nil {
+			<-prev
+		}
+
+		for _, p := range plugins {
+			fn(p)
+		}
+	}()
+}
+
+// PluginEvent is the argument of the methods called by an RPC plugin. Only
+// the fields relevant to the event are set.
+type PluginEvent struct {
+	PID    int
+	OldPID int
+	NewPID int
+	Status ExitStatus
+	Signal string
+}
+
+// rpcPlugin forwards lifecycle events to a JSON-RPC server.
+type rpcPlugin struct {
+	addr string
+
+	mu     sync.Mutex
+	client *rpc.Client
+}
+
+// NewRPCPlugin returns a LifecyclePlugin calling the LifecyclePlugin.OnStart,
+// LifecyclePlugin.OnStop, LifecyclePlugin.OnRestart and
+// LifecyclePlugin.OnSignal methods of the net/rpc server listening on the TCP
+// address addr, with a PluginEvent argument. The JSON-RPC codec is used so the
+// server can be written in any language. Failed calls are logged and the
+// connection is dialed again on the next event.
+func NewRPCPlugin(addr string) LifecyclePlugin {
+	return &rpcPlugin{addr: addr}
+}
+
+func (p *rpcPlugin) OnStart(pid int) {
+	p.call("OnStart", PluginEvent{PID: pid})
+}
+
+func (p *rpcPlugin) OnStop(pid int, status ExitStatus) {
+	p.call("OnStop", PluginEvent{PID: pid, Status: status})
+}
+
+func (p *rpcPlugin) OnRestart(oldPID, newPID int) {
+	p.call("OnRestart", PluginEvent{OldPID: oldPID, NewPID: newPID})
+}
+
+func (p *rpcPlugin) OnSignal(sig os.Signal) {
+	name, err := signalName(sig)
+	if err != nil {
+		name = sig.String()
+	}
+	p.call("OnSignal", PluginEvent{Signal: name})
+}
+
+func (p *rpcPlugin) call(method string, event PluginEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.client == nil {
+		client, err := jsonrpc.Dial("tcp", p.addr)
+		if err != nil {
+			log.Printf("[WARN] plugin %s err: %s", p.addr, err)
+			return
+		}
+		p.client = client
+	}
+
+	var reply struct{}
+	if err := p.client.Call("LifecyclePlugin."+method, &event, &reply); err != nil {
+		log.Printf("[WARN] plugin %s %s err: %s", p.addr, method, err)
+		p.client.Close()
+		p.client = nil
+	}
+}
@@ -0,0 +1,75 @@
+package reenvoy
+
+import (
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPluginServer struct {
+	events chan string
+}
+
+func (s *testPluginServer) OnStart(e *PluginEvent, _ *struct{}) error {
+	s.events <- "start"
+	return nil
+}
+
+func (s *testPluginServer) OnStop(e *PluginEvent, _ *struct{}) error {
+	s.events <- "stop"
+	return nil
+}
+
+func (s *testPluginServer) OnRestart(e *PluginEvent, _ *struct{}) error {
+	s.events <- "restart"
+	return nil
+}
+
+func (s *testPluginServer) OnSignal(e *PluginEvent, _ *struct{}) error {
+	s.events <- "signal " + e.Signal
+	return nil
+}
+
+func TestRPCPlugin(t *testing.T) {
+	t.Parallel()
+
+	srv := &testPluginServer{events: make(chan string, 10)}
+	server := rpc.NewServer()
+	require.Nil(t, server.RegisterName("LifecyclePlugin", srv))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+		}
+	}()
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "exit 3"}
+	c.LifecyclePlugins = []LifecyclePlugin{NewRPCPlugin(ln.Addr().String())}
+
+	require.Nil(t, c.Start())
+	<-c.ExitCh()
+
+	for _, want := range []string{"start", "stop"} {
+		select {
+		case got := <-srv.events:
+			assert.Equal(t, want, got)
+		case <-time.After(time.Second):
+			t.Fatalf("missing %s event", want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package reenvoy
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// podmanAPI is the prefix of the libpod REST endpoints.
+const podmanAPI = "http://podman/v4.0.0/libpod"
+
+// podmanClient talks to the Podman REST API over its unix socket.
+type podmanClient struct {
+	http *http.Client
+}
+
+func newPodmanClient(socket string) *podmanClient {
+	return &podmanClient{
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// do sends a request to the API and decodes the JSON response into out when
+// it is not nil.
+func (c *podmanClient) do(method, path string, in, out interface{}) error {
+	body, err := c.stream(method, path, in)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(body).Decode(out)
+}
+
+// stream sends a request to the API and returns the body of the response,
+// which the caller must close.
+func (c *podmanClient) stream(method, path string, in interface{}) (io.ReadCloser, error) {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, podmanAPI+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("podman %s %s err: %s", method, path, err)
+	}
+
+	// 304 is returned when the container already is in the requested state.
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotModified {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return nil, fmt.Errorf("podman %s %s err: %s (status %d)", method, path, apiErr.Message, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// podmanSocket returns PodmanSocket or the rootless socket of the current user.
+func (r *Process) podmanSocket() string {
+	if r.PodmanSocket != "" {
+		return r.PodmanSocket
+	}
+
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = fmt.Sprintf("/run/user/%d", os.Getuid())
+	}
+	return filepath.Join(dir, "podman", "podman.sock")
+}
+
+// startPodman (re)creates the container when PodmanArgs is given, starts it
+// and watches it until it exits.
+func (r *Process) startPodman() error {
+	c := newPodmanClient(r.podmanSocket())
+	name := url.PathEscape(r.PodmanContainer)
+
+	if len(r.PodmanArgs) > 0 {
+		// Drop the container of a previous run, if any, before creating it again.
+		c.do(http.MethodDelete, "/containers/"+name+"?force=true", nil, nil)
+
+		spec := map[string]interface{}{
+			"name":    r.PodmanContainer,
+			"image":   r.PodmanArgs[0],
+			"command": r.PodmanArgs[1:],
+			"env":     envMap(r.Env),
+			"netns":   map[string]string{"nsmode": "host"},
+		}
+		if err := c.do(http.MethodPost, "/containers/create", spec, nil); err != nil {
+			return err
+		}
+	}
+
+	if err := c.do(http.MethodPost, "/containers/"+name+"/start", nil, nil); err != nil {
+		return err
+	}
+
+	var inspect struct {
+		State struct {
+			Pid int `json:"Pid"`
+		} `json:"State"`
+	}
+	if err := c.do(http.MethodGet, "/containers/"+name+"/json", nil, &inspect); err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] started podman container %s with pid %d", r.PodmanContainer, inspect.State.Pid)
+
+	// Like the pipes of a native child, the output is copied until the
+	// container exits, before its exit is reported.
+	copied := make(chan struct{})
+	if stdout, stderr := r.outputs(); stdout != nil || stderr != nil {
+		go func() {
+			defer close(copied)
+			if err := c.copyLogs(name, stdout, stderr); err != nil {
+				log.Printf("[WARN] failed to copy output of podman container %s: %s", r.PodmanContainer, err)
+			}
+		}()
+	} else {
+		close(copied)
+	}
+
+	r.podman = c
+	r.podmanPID = inspect.State.Pid
+	r.watch(func() (int, *os.ProcessState, error) {
+		var code int
+		err := c.do(http.MethodPost, "/containers/"+name+"/wait?condition=exited", nil, &code)
+		<-copied
+		if err != nil {
+			log.Printf("[WARN] waiting for podman container %s: %s", r.PodmanContainer, err)
+			return ExitCodeError, nil, err
+		}
+		return code, nil, nil
+	})
+
+	return nil
+}
+
+// copyLogs follows the output of the container name, from its start, into
+// stdout and stderr, either of which may be nil, until the container exits.
+// The API multiplexes both streams in frames made of the stream, 1 for stdout
+// and 2 for stderr, 3 bytes of padding, the big endian size of the data and
+// the data.
+func (c *podmanClient) copyLogs(name string, stdout, stderr io.Writer) error {
+	body, err := c.stream(http.MethodGet, "/containers/"+name+"/logs?follow=true&stdout=true&stderr=true", nil)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(body, header[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		w := stdout
+		if header[0] == 2 {
+			w = stderr
+		}
+		if w == nil {
+			w = ioutil.Discard
+		}
+
+		if _, err := io.CopyN(w, body, int64(binary.BigEndian.Uint32(header[4:]))); err != nil {
+			return err
+		}
+	}
+}
+
+// signalPodman delivers s to the container through the API.
+func (r *Process) signalPodman(s os.Signal) error {
+	sig, ok := s.(syscall.Signal)
+	if !ok {
+		return fmt.Errorf("unsupported signal %q", s)
+	}
+
+	path := fmt.Sprintf("/containers/%s/kill?signal=%d", url.PathEscape(r.PodmanContainer), int(sig))
+	return r.podman.do(http.MethodPost, path, nil, nil)
+}
+
+// killPodman sends the KillSignal to the container, waiting up to KillTimeout
+// for it to exit before killing it.
+func (r *Process) killPodman() {
+	select {
+	case <-r.stopCh:
+	case <-r.randomSplay():
+	}
+
+	if r.KillSignal != nil {
+		if err := r.signalPodman(r.KillSignal); err == nil {
+			select {
+			case <-r.stopCh:
+			case <-r.waitCh:
+				return
+			case <-r.after(r.KillTimeout):
+			}
+		}
+	}
+
+	r.signalPodman(os.Kill)
+}
+
+// removePodman removes a container created from PodmanArgs once it is gone;
+// existing containers are left in place for the next start.
+func (r *Process) removePodman() {
+	if len(r.PodmanArgs) > 0 {
+		path := "/containers/" + url.PathEscape(r.PodmanContainer) + "?force=true"
+		if err := r.podman.do(http.MethodDelete, path, nil, nil); err != nil {
+			log.Printf("[WARN] failed to remove podman container %s: %s", r.PodmanContainer, err)
+		}
+	}
+
+	r.podman = nil
+	r.podmanPID = 0
+}
@@ -0,0 +1,138 @@
+package reenvoy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-gatedio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePodman serves the subset of the libpod API used by the podman backend.
+type fakePodman struct {
+	sync.Mutex
+	calls  []string
+	exited chan struct{}
+	logs   []byte
+}
+
+func (f *fakePodman) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.Lock()
+	f.calls = append(f.calls, req.Method+" "+strings.TrimPrefix(req.URL.Path, "/v4.0.0/libpod"))
+	f.Unlock()
+
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/start"):
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasSuffix(req.URL.Path, "/json"):
+		fmt.Fprint(w, `{"State":{"Pid":4242}}`)
+	case strings.HasSuffix(req.URL.Path, "/kill"):
+		if req.URL.Query().Get("signal") == "9" {
+			close(f.exited)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasSuffix(req.URL.Path, "/wait"):
+		<-f.exited
+		fmt.Fprint(w, "137")
+	case strings.HasSuffix(req.URL.Path, "/logs"):
+		w.Write(f.logs)
+		w.(http.Flusher).Flush()
+		<-f.exited
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestPodman(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "podman.sock")
+	l, err := net.Listen("unix", socket)
+	require.Nil(t, err)
+
+	api := &fakePodman{exited: make(chan struct{})}
+	go http.Serve(l, api)
+	defer l.Close()
+
+	c := testProcess(t)
+	c.PodmanContainer = "envoy"
+	c.PodmanSocket = socket
+	c.KillSignal = nil
+
+	require.Nil(t, c.Start())
+	assert.Equal(t, PID(4242), c.GetPID())
+
+	c.Kill()
+	assert.Equal(t, PID(0), c.GetPID())
+
+	select {
+	case code := <-c.ExitCh():
+		assert.Equal(t, 137, code)
+	case <-time.After(fileWaitSleepDelay):
+		t.Fatal("process should have exited")
+	}
+
+	// The wait request races with the others, so compare sorted calls.
+	api.Lock()
+	defer api.Unlock()
+	sort.Strings(api.calls)
+	assert.Equal(t, []string{
+		"GET /containers/envoy/json",
+		"POST /containers/envoy/kill",
+		"POST /containers/envoy/start",
+		"POST /containers/envoy/wait",
+	}, api.calls)
+}
+
+func TestPodman_output(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "podman.sock")
+	l, err := net.Listen("unix", socket)
+	require.Nil(t, err)
+
+	api := &fakePodman{
+		exited: make(chan struct{}),
+		logs: []byte("\x01\x00\x00\x00\x00\x00\x00\x04out\n" +
+			"\x02\x00\x00\x00\x00\x00\x00\x04err\n"),
+	}
+	go http.Serve(l, api)
+	defer l.Close()
+
+	stdout, stderr := gatedio.NewByteBuffer(), gatedio.NewByteBuffer()
+	c := testProcess(t)
+	c.PodmanContainer = "envoy"
+	c.PodmanSocket = socket
+	c.KillSignal = nil
+	c.Stdout, c.StdErr = stdout, stderr
+
+	require.Nil(t, c.Start())
+	c.Kill()
+
+	select {
+	case <-c.ExitCh():
+	case <-time.After(fileWaitSleepDelay):
+		t.Fatal("process should have exited")
+	}
+
+	assert.Equal(t, "out\n", stdout.String())
+	assert.Equal(t, "err\n", stderr.String())
+}
@@ -0,0 +1,107 @@
+package reenvoy
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// pressureRoot is where the kernel reports pressure stall information.
+	pressureRoot = "/proc/pressure"
+
+	// pressurePollInterval is how often pressure is checked. The kernel
+	// updates the averages every two seconds.
+	pressurePollInterval = 2 * time.Second
+)
+
+// systemPressure returns the highest share of time, between 0 and 1, during
+// which some tasks stalled on memory or CPU over the last 10 seconds.
+func systemPressure() (float64, error) {
+	var max float64
+	for _, resource := range []string{"memory", "cpu"} {
+		p, err := readPressure(filepath.Join(pressureRoot, resource))
+		if err != nil {
+			return 0, err
+		}
+		if p > max {
+			max = p
+		}
+	}
+	return max, nil
+}
+
+// readPressure returns the "some avg10" value of a PSI file as a fraction.
+func readPressure(path string) (float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "some" {
+			continue
+		}
+
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, "avg10=") {
+				avg, err := strconv.ParseFloat(strings.TrimPrefix(field, "avg10="), 64)
+				if err != nil {
+					return 0, fmt.Errorf("parse %s err: %s", path, err)
+				}
+				return avg / 100, nil
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no avg10 in %s", path)
+}
+
+// watchPressure sends ResourcePressureSignal to the process each time the
+// system pressure rises above ResourcePressureThreshold. It returns when the
+// process exits.
+func (r *Process) watchPressure(waitCh <-chan struct{}) {
+	ticker := time.NewTicker(pressurePollInterval)
+	defer ticker.Stop()
+
+	above := false
+	for {
+		select {
+		case <-waitCh:
+			return
+		case <-ticker.C:
+		}
+
+		pressure, err := systemPressure()
+		if err != nil {
+			log.Printf("[WARN] unable to read resource pressure, not watching it anymore: %s", err)
+			return
+		}
+
+		if pressure <= r.ResourcePressureThreshold {
+			above = false
+			continue
+		}
+
+		if !above {
+			above = true
+			log.Printf("[WARN] resource pressure %.2f above %.2f, sending %q to process %d",
+				pressure, r.ResourcePressureThreshold, r.ResourcePressureSignal, r.GetPID())
+
+			r.RLock()
+			r.signal(r.ResourcePressureSignal)
+			r.RUnlock()
+		}
+	}
+}
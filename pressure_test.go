@@ -0,0 +1,34 @@
+package reenvoy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSystemPressure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(root string) { pressureRoot = root }(pressureRoot)
+	pressureRoot = dir
+
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "memory"), []byte(
+		"some avg10=42.50 avg60=10.00 avg300=1.00 total=12345\n"+
+			"full avg10=20.00 avg60=5.00 avg300=0.50 total=6789\n"), 0644))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "cpu"), []byte(
+		"some avg10=7.00 avg60=3.00 avg300=1.00 total=999\n"), 0644))
+
+	p, err := systemPressure()
+	require.Nil(t, err)
+	assert.InDelta(t, 0.425, p, 0.0001)
+
+	require.Nil(t, os.Remove(filepath.Join(dir, "cpu")))
+	_, err = systemPressure()
+	assert.NotNil(t, err)
+}
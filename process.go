@@ -1,6 +1,7 @@
 package reenvoy
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -8,6 +9,7 @@ import (
 	"math/rand"
 	"os"
 	"os/exec"
+	"runtime"
 	"strconv"
 	"sync"
 	"syscall"
@@ -25,6 +27,36 @@ var (
 	// to run.
 	ErrMissingCommand = errors.New("missing command")
 
+	// ErrAlreadyRunning is the error returned when the LockFile of a process
+	// is held by another instance.
+	ErrAlreadyRunning = errors.New("process already running")
+
+	// ErrNotRunning is the error returned when an operation needs a running
+	// process.
+	ErrNotRunning = errors.New("process is not running")
+
+	// ErrNoMessageQueue is the error returned by SendMessage and
+	// ReceiveMessage when no message queue is open for the process.
+	ErrNoMessageQueue = errors.New("no message queue")
+
+	// ErrStdinNotPiped is the error returned by SendInput when the standard
+	// input of the child is not a pipe, because Stdin is set or the process is
+	// not a local command.
+	ErrStdinNotPiped = errors.New("stdin is not piped to the process, Stdin and SendInput are mutually exclusive")
+
+	// ErrReloadTimeout is the error returned by Restart when the process did
+	// not acknowledge the ReloadSignal within ReloadTimeout and was restarted
+	// instead.
+	ErrReloadTimeout = errors.New("process did not acknowledge reload")
+
+	// ErrStopDeadline is the error returned by StopWithDeadline when the
+	// process had to be force-killed because the deadline was reached.
+	ErrStopDeadline = errors.New("process did not stop before deadline")
+
+	// ErrNoFlameGraph is the error returned by FlameGraph when no profile was
+	// captured yet.
+	ErrNoFlameGraph = errors.New("no flame graph captured yet")
+
 	// ExitCodeOK is the default OK exit code.
 	ExitCodeOK = 0
 
@@ -46,13 +78,14 @@ type Process struct {
 	sync.RWMutex
 
 	// ErrCh and DoneCh are channels where errors and finish notifications occur.
-	ErrCh  chan error
-	DoneCh chan struct{}
+	ErrCh  chan error    `json:"-"`
+	DoneCh chan struct{} `json:"-"`
 
 	// Command is the name of the command to execute. Args are the list of
-	// arguments to pass when starting the command.
-	command string
-	args    []string
+	// arguments to pass when starting the command. When Command is empty,
+	// envoy is run, natively or in docker, with ConfigPath.
+	Command string
+	Args    []string
 
 	// Env specifies the environment of the process.
 	// Each entry is of the form "key=value".
@@ -62,13 +95,27 @@ type Process struct {
 	// value in the slice for each duplicate key is used.
 	Env []string
 
+	// Labels classify the process, e.g. tier=frontend, for the code managing
+	// it. They are kept in the configuration but not used by the process.
+	Labels map[string]string
+
+	// startEnv is the environment the current child was started with.
+	startEnv []string
+
 	// Timeout is the maximum amount of time to allow the command to execute. If
 	// set to 0, the command is permitted to run infinitely.
 	Timeout time.Duration
 
 	// ReloadSignal is the signal to send to reload this process. This value may
 	// be nil.
-	ReloadSignal os.Signal
+	ReloadSignal os.Signal `json:"-"`
+
+	// ReloadTimeout makes Restart wait, after sending the ReloadSignal, for
+	// the process to exit or for ReloadAckProbe to succeed. When neither
+	// happens in time the process is restarted and Restart returns an error
+	// wrapping ErrReloadTimeout. Zero keeps reloads fire-and-forget.
+	ReloadTimeout  time.Duration
+	ReloadAckProbe func() error `json:"-"`
 
 	// ParentShutdownTimes The time in second that Envoy will wait before shutting down the parent process during a hot restart.
 	// Readmore at https://www.envoyproxy.io/docs/envoy/v1.7.0/intro/arch_overview/hot_restart#arch-overview-hot-restart
@@ -79,12 +126,253 @@ type Process struct {
 
 	DockerContainer bool
 	ConfigPath      string
-	restartEpoch    int
+
+	// NUMAPinning binds the CPUs and the memory of the process to NUMANode by
+	// running it under numactl, which must be installed. NUMANodes lists the
+	// nodes available on the host.
+	NUMAPinning bool
+	NUMANode    int
+
+	// AppArmorProfile confines the process to the named AppArmor profile by
+	// running it under aa-exec, which must be installed and the profile
+	// loaded. Start fails on hosts without AppArmor, unless
+	// AppArmorFallbackToPermissive is set to run the process unconfined.
+	AppArmorProfile              string
+	AppArmorFallbackToPermissive bool
+
+	// CgroupV1Config applies resource limits to the process through a cgroup
+	// v1 group created for it, which is removed once the process is gone. With
+	// CgroupAutoDetect the same limits are applied through the unified cgroup
+	// v2 hierarchy when the host has one, falling back to v1 otherwise.
+	CgroupV1Config   *CgroupV1Config
+	CgroupAutoDetect bool
+	cgroupPaths      []string
+
+	// BenchmarkMode makes Start run the command BenchmarkRuns times in a row
+	// instead of starting it once, measuring how long each run takes to
+	// produce its first byte of output and to exit. Each run is bounded by
+	// Timeout when set. The measurements are available from BenchmarkResults.
+	BenchmarkMode  bool
+	BenchmarkRuns  int
+	benchmarkStats *BenchmarkStats
+
+	// SLATarget is the fraction of UptimeWindow the process is expected to be
+	// running, e.g. 0.999. OnSLAViolation is called once per window when the
+	// uptime drops below it. The window restarts every UptimeWindow, or never
+	// when it is zero.
+	SLATarget      float64
+	UptimeWindow   time.Duration
+	OnSLAViolation func(target, actual float64) `json:"-"`
+	uptime         uptimeTracker
+
+	// PagerDutyKey is the routing key of a PagerDuty Events API v2
+	// integration. When set, an incident is triggered once the process has
+	// not been running for PagerDutyAlertAfter, e.g. because it keeps
+	// crashing, and resolved once it has been running again for
+	// PagerDutyRecoveryWindow. Stopping the process triggers nothing.
+	PagerDutyKey            string
+	PagerDutyAlertAfter     time.Duration
+	PagerDutyRecoveryWindow time.Duration
+	pagerDuty               pagerDutyAlert
+
+	// MetricsPattern is a regular expression matched against every line the
+	// process writes to stdout and stderr. The value of each named group, e.g.
+	// `latency=(?P<latency>\d+)ms`, is parsed as a number and made available
+	// through ParsedMetrics.
+	MetricsPattern string
+	metrics        *metricsParser
+
+	// OutputTimeout is the longest the process may go without writing to
+	// stdout or stderr. Past it the process is considered hung: OnOutputTimeout
+	// is called and the process is killed. Zero disables the check.
+	OutputTimeout   time.Duration
+	OnOutputTimeout func() `json:"-"`
+	idle            *idleWatch
+
+	// HeartbeatFile is a file whose modification time is updated every
+	// HeartbeatInterval while the process runs, so monitoring tools can detect
+	// a hung supervisor from its age. When HeartbeatTimeout is set, the process
+	// is killed once the file has not been touched for that long; leave
+	// HeartbeatInterval at zero to have the child, or another agent, touch it.
+	HeartbeatFile     string
+	HeartbeatInterval time.Duration
+	HeartbeatTimeout  time.Duration
+
+	// MessageQueueDir is a directory where two named pipes are created for
+	// newline delimited messages between the supervisor and the child, see
+	// SendMessage and ReceiveMessage. The child finds the pipes to read from
+	// and write to in REENVOY_MSG_IN and REENVOY_MSG_OUT.
+	MessageQueueDir string
+	msgQueue        *messageQueue
+
+	// BinaryWatcher restarts the process when the binary at BinaryPath, by
+	// default the resolved Command, changes. The restart happens once the
+	// file was left unchanged for BinaryUpdateDebounce, so a binary being
+	// copied in place is not run half-written. The process is restarted even
+	// when ReloadSignal is set, since a new binary can't be loaded by a
+	// reload.
+	BinaryWatcher        bool
+	BinaryPath           string
+	BinaryUpdateDebounce time.Duration
+
+	// ResourcePressureSignal is sent to the process when the memory or CPU
+	// pressure of the system, from /proc/pressure on Linux, rises above
+	// ResourcePressureThreshold (between 0 and 1), so it can shed load before
+	// the OOM killer steps in. It is sent again only after the pressure went
+	// back under the threshold.
+	ResourcePressureSignal    os.Signal `json:"-"`
+	ResourcePressureThreshold float64
+
+	// ContinuousProfile profiles the process with perf on Linux, for
+	// ProfileInterval at a time, one minute by default, and saves a flame
+	// graph of each profile in ProfileOutputDir, the temporary directory by
+	// default. FlameGraph returns the most recent one.
+	ContinuousProfile bool
+	ProfileInterval   time.Duration
+	ProfileOutputDir  string
+	flameGraph        string
+
+	// MemoryLeakDetect samples the resident memory of the process every
+	// minute, from /proc on Linux, and reports a leak when the growth rate
+	// fitted over MemoryLeakWindow, one hour by default, is above
+	// MemoryLeakThreshold bytes per minute. OnMemoryLeak, if set, is then
+	// called with the rate from the goroutine sampling the memory.
+	MemoryLeakDetect    bool
+	MemoryLeakWindow    time.Duration
+	MemoryLeakThreshold int64
+	OnMemoryLeak        func(growthRateBytes int64) `json:"-"`
+
+	// ZoneAwareRestart makes Restart wait, up to ZoneRestartTimeout, for
+	// other zones of the deployment to finish restarting, as reported by
+	// ZoneCoordinator, so a rolling deploy never restarts every zone at once.
+	// ZoneID identifies the zone of this process.
+	ZoneAwareRestart   bool
+	ZoneID             string
+	ZoneRestartTimeout time.Duration
+	ZoneCoordinator    ZoneCoordinator `json:"-"`
+
+	// DNSServers and DNSSearchDomains give the child a resolv.conf of its own,
+	// pointed at by the RESOLV_CONF environment variable for resolvers
+	// honoring it; LOCALDOMAIN carries the search domains for glibc and musl.
+	// With DNSMountNamespace, the child runs in a new mount namespace with the
+	// file bind mounted at /etc/resolv.conf, which needs CAP_SYS_ADMIN and
+	// the unshare utility.
+	DNSServers        []string
+	DNSSearchDomains  []string
+	DNSMountNamespace bool
+	resolvConf        string
+
+	// SignalMode selects whether signals reach only the child, its process
+	// group, or all of its descendants.
+	SignalMode SignalDeliveryMode
+
+	// KillProcessGroup runs the child in a process group of its own and sends
+	// the signals stopping it, from Kill, Stop and Restart, to the whole
+	// group, so processes forked by the child don't outlive it. Other
+	// signals follow SignalMode.
+	KillProcessGroup bool
+
+	// ShutdownStages replaces the KillSignal and KillTimeout of Stop with a
+	// sequence of signals, each followed by a wait for the process to exit.
+	// The process is killed if it is still running after the last stage.
+	ShutdownStages []ShutdownStage
+
+	// LifecyclePlugins are notified when the process starts, stops, restarts
+	// or is signalled. NewRPCPlugin forwards the events to another process.
+	LifecyclePlugins []LifecyclePlugin `json:"-"`
+	pluginQueue      pluginQueue
+
+	// OnEvent is called on every lifecycle transition of the process. Unlike
+	// the LifecyclePlugins, it is called synchronously from the goroutine
+	// making the transition, which it delays while running, and possibly with
+	// the lock held: it must not call the methods of the process.
+	OnEvent func(ProcessEvent) `json:"-"`
+
+	// ChaosMode makes Start, Stop, Restart, Kill, Signal and UrgentSignal
+	// fail with a probability of ChaosProbability, for testing code managing
+	// processes: they are delayed by up to 500ms, return an error wrapping
+	// ErrChaos, or panic. Stop and Kill fail by returning without doing
+	// anything. Never enable it in production.
+	ChaosMode        bool
+	ChaosProbability float64
+
+	// TestMode makes the process deterministic for unit tests: TestClock, when
+	// set, drives the splay, the kill, reload, startup and stop timeouts, the
+	// restart backoff and window and the spawn rate, and TestSignalDelivery,
+	// when set, receives the signals instead of the child, which is then never
+	// signalled at all.
+	TestMode           bool
+	TestClock          Clock                 `json:"-"`
+	TestSignalDelivery func(os.Signal) error `json:"-"`
+
+	// IdempotentStart makes Start a no-op returning nil while the process is
+	// already running, instead of spawning another instance.
+	IdempotentStart bool
+
+	// StartTrigger delays Start until an external event, see FileTrigger,
+	// HTTPTrigger, SignalTrigger and TimeTrigger. Restarts don't wait for it.
+	// StopTrigger stops the process when it fires, e.g. for maintenance with
+	// a TimeTrigger; it is armed again whenever a child starts.
+	StartTrigger Trigger `json:"-"`
+	StopTrigger  Trigger `json:"-"`
+
+	// cancelStart cuts short the wait of Start for StartTrigger, for Stop and
+	// Kill to prevent a pending start.
+	cancelStart context.CancelFunc
+
+	// PIDFile is the path of a file kept up to date with the PID of the child
+	// for external tools. It is replaced whenever a child starts, failing
+	// Start when it can't be written, and removed by Stop and Kill.
+	PIDFile string
+
+	// StartupProbe reports whether a newly started child is ready, e.g. by
+	// connecting to its admin port. When set, Start and Restart poll it every
+	// StartupPollInterval, 200ms by default, and only return once it
+	// succeeds. They fail when the child exits first or the probe still fails
+	// after StartupTimeout, leaving the child to Stop; zero waits forever.
+	StartupProbe        func() error `json:"-"`
+	StartupTimeout      time.Duration
+	StartupPollInterval time.Duration
+
+	// MaxRestarts is the number of times the process is restarted after
+	// exiting on its own, -1 for no limit. Exits caused by Stop, Kill or
+	// Restart are not counted. The restarts wait RestartDelay, multiplied by
+	// RestartBackoffFactor after each attempt, plus the Splay. The count is
+	// reset once a run lasts RestartWindow. ExitCh only fires once the
+	// restarts are exhausted.
+	MaxRestarts          int
+	RestartDelay         time.Duration
+	RestartBackoffFactor float64
+	RestartWindow        time.Duration
+	restarts             int
+	startedAt            time.Time
+	restartExitCh        chan int
+
+	// MaxSpawnRate is the number of spawns allowed within
+	// SpawnRateMonitorWindow before the process is considered to be in a
+	// restart loop. OnSpawnRateExceeded is then called, from its own
+	// goroutine, with the number of spawns in the window.
+	MaxSpawnRate           int
+	SpawnRateMonitorWindow time.Duration
+	OnSpawnRateExceeded    func(count int) `json:"-"`
+	spawns                 []time.Time
+
+	// HotPatch patches the running process in place, e.g. by injecting a
+	// shared library with ptrace. It is called with the PID of the child by
+	// ApplyHotPatch. MemoryPatch builds a simple implementation overwriting
+	// memory of the process.
+	HotPatch func(pid int) error `json:"-"`
+
+	restartEpoch int
 
 	// exec is the actual child process under management.
 	exec *exec.Cmd
 	// exitCh is the channel where the processes exit will be returned.
 	exitCh chan int
+	// exitStatusCh receives the ExitEvent of the current child.
+	exitStatusCh chan ExitEvent
+	// waitCh is closed once the child has exited and been reaped.
+	waitCh chan struct{}
 
 	// Splay is the maximum random amount of time to wait before sending signals.
 	// This option helps reduce the thundering herd problem by effectively
@@ -95,12 +383,47 @@ type Process struct {
 
 	// KillSignal is the signal to send to gracefully kill this process. This
 	// value may be nil.
-	KillSignal os.Signal
+	KillSignal os.Signal `json:"-"`
 
 	// KillTimeout is the amount of time to wait for the process to gracefully
 	// terminate before force-killing.
 	KillTimeout time.Duration
 
+	// TempDir is a scratch directory owned by the process. When set, it is
+	// created empty before every start (removing whatever the previous run left
+	// behind) and removed once the process is stopped or killed. The path is
+	// exported to the child as TMPDIR, XDG_RUNTIME_DIR and REENVOY_TMPDIR.
+	TempDir string
+
+	// LockFile is the path of a file on which an exclusive flock is held for as
+	// long as the process runs, so two instances of the same configuration can
+	// not run at the same time. Start returns ErrAlreadyRunning when the lock
+	// is held elsewhere.
+	LockFile string
+	lockFile *os.File
+
+	// PodmanContainer is the name of a Podman container to manage instead of a
+	// local command. The container is driven through the Podman REST API on
+	// PodmanSocket, which defaults to the rootless socket of the current user.
+	// When PodmanArgs is empty the container must already exist; otherwise
+	// PodmanArgs holds the image followed by the command, as given to
+	// `podman run`, and the container is recreated from them on every start.
+	// GetPID reports the PID of the container's init process.
+	PodmanContainer string
+	PodmanArgs      []string
+	PodmanSocket    string
+	podman          *podmanClient
+	podmanPID       int
+
+	// WASMFile is the path of a WebAssembly module to run with WASMRuntime
+	// instead of a local command. The module gets the process environment,
+	// arguments and standard streams through WASI. Since it does not run as a
+	// separate OS process, GetPID reports 0 and only the kill signal can be
+	// delivered to it.
+	WASMFile    string
+	WASMRuntime WASMRuntime `json:"-"`
+	wasmCancel  context.CancelFunc
+
 	// stopLock is the mutex to lock when stopping. stopCh is the circuit breaker
 	// to force-terminate any waiting splays to kill the process now. stopped is
 	// a boolean that tells us if we have previously been stopped.
@@ -108,9 +431,40 @@ type Process struct {
 	stopped  bool
 	stopCh   chan struct{}
 
-	Stdin  io.Reader
-	Stdout io.Writer
-	StdErr io.Writer
+	// StdinEncoding, StdoutEncoding and StderrEncoding are the encodings of
+	// the data read from Stdin and written to Stdout and StdErr, one of
+	// EncodingRaw (the default), EncodingBase64 and EncodingHex. They allow
+	// binary streams to go through text-based channels.
+	StdinEncoding  string
+	StdoutEncoding string
+	StderrEncoding string
+
+	// MaxOutputBytesPerMinute limits the output passed on to Stdout and
+	// StdErr combined, over a sliding window of one minute. Output above the
+	// limit is dropped, see DroppedOutputBytes, so a process stuck in an error
+	// loop can't flood the log pipeline.
+	MaxOutputBytesPerMinute int64
+	outputLimit             *outputLimiter
+
+	// LineSeparator is the byte ending the lines of output parsed by the line
+	// oriented features, such as MetricsPattern: '\n' when zero, '\r' or
+	// '\x00' for processes delimiting their output otherwise. As the zero
+	// value means '\n', the NUL byte also needs LineSeparatorSet.
+	LineSeparator    byte
+	LineSeparatorSet bool
+
+	// StdinRateLimit throttles the data passed from Stdin to the child to the
+	// given number of bytes per second, for processes that can't keep up with
+	// their input. Zero means unlimited.
+	StdinRateLimit int
+
+	// Stdin is the standard input of the child. When nil, the standard input
+	// of a local command is a pipe written to by SendInput.
+	Stdin     io.Reader `json:"-"`
+	stdinPipe io.WriteCloser
+
+	Stdout io.Writer `json:"-"`
+	StdErr io.Writer `json:"-"`
 }
 
 // NewProc creates a new child process for management with high-level APIs for
@@ -133,25 +487,75 @@ func NewProc() (Child, error) {
 
 // Start starts and begins execution of the child process.
 func (r *Process) Start() error {
+	if err := r.chaos("Start"); err != nil {
+		return err
+	}
+
+	// Starting again undoes a previous Stop, so the new child is restarted
+	// automatically and reports its exit like the first one did.
+	r.stopLock.Lock()
+	r.stopped = false
+	r.stopLock.Unlock()
+
+	ctx := context.Background()
+	if r.StartTrigger != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+
+		if err := r.waitStartTrigger(ctx, cancel); err != nil {
+			return err
+		}
+	}
+
 	r.Lock()
-	defer r.Unlock()
-	return r.start()
+
+	// Stop or Kill may have been called once the trigger fired.
+	r.cancelStart = nil
+	if err := ctx.Err(); err != nil {
+		r.Unlock()
+		return fmt.Errorf("start trigger err: %s", err)
+	}
+
+	if r.IdempotentStart && r.running() {
+		log.Printf("[DEBUG] process %d already running", r.pid())
+		r.Unlock()
+		return nil
+	}
+
+	err := r.start()
+	waitCh := r.waitCh
+	r.Unlock()
+
+	if err != nil {
+		return err
+	}
+	return r.waitStartup(waitCh)
 }
 
 // Restart send the reload signal to the process and does not wait for a response
 func (r *Process) Restart() error {
+	if err := r.chaos("Restart"); err != nil {
+		return err
+	}
+
+	if r.ZoneAwareRestart {
+		end := r.beginZoneRestart()
+		defer end()
+	}
 
 	if r.ReloadSignal == nil {
 		log.Println("[INFO] restarting process")
 
 		r.Lock()
-		defer r.Unlock()
-
-		r.kill()
-		log.Println("[INFO] kill old process")
+		err := r.restart()
+		waitCh := r.waitCh
+		r.Unlock()
 
-		log.Println("[INFO] start new process")
-		return r.start()
+		if err != nil {
+			return err
+		}
+		return r.waitStartup(waitCh)
 	}
 
 	log.Println("[INFO] reloading process")
@@ -159,14 +563,36 @@ func (r *Process) Restart() error {
 	// We only need read lock here because neither the process nor the exit
 	// channel are changging
 	r.RLock()
-	defer r.RUnlock()
+	err := r.reload()
+	waitCh := r.waitCh
+	r.RUnlock()
 
-	return r.reload()
+	if err != nil || r.ReloadTimeout == 0 || r.waitReload(waitCh) {
+		return err
+	}
+	return r.restartAfterReloadTimeout()
 }
 
-func (r *Process) commandWithDocker() {
-	r.command = "docker"
-	r.args = []string{
+// restart replaces the child by a new one. It must be called with the lock
+// held.
+func (r *Process) restart() error {
+	oldPID := int(r.pid())
+	r.kill()
+	log.Println("[INFO] kill old process")
+
+	log.Println("[INFO] start new process")
+	if err := r.start(); err != nil {
+		return err
+	}
+
+	newPID := int(r.pid())
+	r.notify(func(p LifecyclePlugin) { p.OnRestart(oldPID, newPID) })
+	r.emit(ProcessEvent{Type: EventRestarted, PID: newPID})
+	return nil
+}
+
+func (r *Process) commandWithDocker() (string, []string) {
+	return "docker", []string{
 		"run",
 		"--network",
 		"host",
@@ -187,9 +613,8 @@ func (r *Process) commandWithDocker() {
 	}
 }
 
-func (r *Process) commandEnvoy() {
-	r.command = "envoy"
-	r.args = []string{
+func (r *Process) commandEnvoy() (string, []string) {
+	return "envoy", []string{
 		"--mode",
 		"serve",
 		"--restart-epoch",
@@ -204,60 +629,223 @@ func (r *Process) commandEnvoy() {
 }
 
 func (r *Process) start() error {
-	if r.DockerContainer {
-		r.commandWithDocker()
-	} else {
-		r.commandEnvoy()
+	if err := r.checkEncodings(); err != nil {
+		return err
+	}
+
+	if err := r.prepareMetrics(); err != nil {
+		return err
+	}
+
+	if err := r.acquireLock(); err != nil {
+		return err
+	}
+
+	if err := r.prepareTempDir(); err != nil {
+		r.releaseLock()
+		return err
+	}
+
+	// Until the child is started, the temp dir belongs to nobody.
+	started := false
+	defer func() {
+		if !started {
+			r.removeTempDir()
+		}
+	}()
+
+	if r.HeartbeatFile != "" {
+		if err := r.touchHeartbeat(); err != nil {
+			r.releaseLock()
+			return err
+		}
+	}
+
+	if err := r.prepareResolvConf(); err != nil {
+		r.releaseLock()
+		return err
+	}
+
+	if err := r.prepareMessageQueue(); err != nil {
+		r.removeResolvConf()
+		r.releaseLock()
+		return err
+	}
+
+	r.prepareOutputLimit()
+
+	r.idle = nil
+	if r.OutputTimeout > 0 {
+		r.idle = &idleWatch{}
+		r.idle.touch()
+	}
+
+	var err error
+	switch {
+	case r.PodmanContainer != "":
+		err = r.startPodman()
+	case r.WASMFile != "":
+		err = r.startWASM()
+	case r.BenchmarkMode:
+		err = r.runBenchmark()
+	default:
+		err = r.startCommand()
+	}
+
+	if err != nil {
+		r.closeMessageQueue()
+		r.removeResolvConf()
+		r.releaseLock()
+		return err
 	}
+	started = true
 
-	cmd := exec.Command(r.command, r.args...)
-	cmd.Stdin = r.Stdin
-	cmd.Stderr = r.StdErr
-	cmd.Stdout = r.Stdout
-	cmd.Env = r.Env
+	if r.running() && r.PIDFile != "" {
+		if err := r.writePIDFile(); err != nil {
+			r.kill()
+			return err
+		}
+	}
+
+	if r.running() {
+		r.markUp(r.waitCh)
+		r.pagerDutyUp(r.waitCh)
+		r.recordSpawn()
+		r.startedAt = r.now()
+
+		if r.idle != nil {
+			go r.watchOutput(r.idle, r.waitCh)
+		}
+
+		if r.HeartbeatFile != "" && (r.HeartbeatInterval > 0 || r.HeartbeatTimeout > 0) {
+			go r.heartbeat(r.waitCh)
+		}
+
+		if r.BinaryWatcher {
+			r.watchBinary(r.waitCh)
+		}
+
+		if r.ResourcePressureSignal != nil {
+			go r.watchPressure(r.waitCh)
+		}
+
+		if r.StopTrigger != nil {
+			go r.watchStopTrigger(r.waitCh)
+		}
+
+		if pid := int(r.pid()); r.ContinuousProfile && pid != 0 {
+			go r.watchProfile(perfCommand, pid, r.waitCh)
+		}
+
+		if pid := int(r.pid()); r.MemoryLeakDetect && pid != 0 {
+			go r.watchMemoryLeak(readRSS, memoryLeakSampleInterval, pid, r.waitCh)
+		}
+	}
+	return nil
+}
+
+// commandLine builds the command and arguments of the child, wrapped as
+// needed by the features enabled on the process.
+func (r *Process) commandLine() (string, []string, error) {
+	var command string
+	var args []string
+	switch {
+	case r.Command != "":
+		command, args = r.Command, r.Args
+	case r.DockerContainer:
+		command, args = r.commandWithDocker()
+	default:
+		command, args = r.commandEnvoy()
+	}
+
+	var err error
+	if r.AppArmorProfile != "" {
+		if command, args, err = r.appArmorCommand(command, args); err != nil {
+			return "", nil, err
+		}
+	}
+
+	if r.NUMAPinning {
+		if command, args, err = r.numaCommand(command, args); err != nil {
+			return "", nil, err
+		}
+	}
+
+	if r.DNSMountNamespace && r.resolvConf != "" {
+		command, args = r.dnsCommand(command, args)
+	}
+
+	return command, args, nil
+}
+
+// childEnv returns the environment of the child, extended with the
+// variables of the features enabled on the process.
+func (r *Process) childEnv() []string {
+	return r.dnsEnv(r.messageQueueEnv(r.tempDirEnv(r.Env)))
+}
+
+// startCommand runs envoy, natively or in docker, as a child process.
+func (r *Process) startCommand() error {
+	command, args, err := r.commandLine()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(command, args...)
+	cmd.Stdin = r.input()
+	cmd.Stdout, cmd.Stderr = r.outputs()
+	cmd.Env = r.childEnv()
+
+	// Without a Stdin, the standard input is piped for SendInput.
+	var stdinPipe io.WriteCloser
+	if cmd.Stdin == nil {
+		if stdinPipe, err = cmd.StdinPipe(); err != nil {
+			return fmt.Errorf("stdin pipe err: %s", err)
+		}
+	}
+
+	r.setProcessGroup(cmd)
 
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("%s err: %s", r.StdErr, err)
 	}
 
+	r.startEnv = cmd.Env
+	if r.startEnv == nil {
+		r.startEnv = os.Environ()
+	}
+
 	r.exec = cmd
+	r.stdinPipe = stdinPipe
+	if err := r.applyCgroup(cmd.Process.Pid); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		r.release()
+		return err
+	}
 
-	// Create a new exitCh so that previously invoked commands (if any) don't
-	// cause us to exit, and start a goroutine to wait for that process to end.
-	exitCh := make(chan int, 1)
-	go func() {
-		var code int
+	if err := assignJobObject(cmd.Process); err != nil {
+		log.Printf("[WARN] unable to assign process %d to a job object: %s", cmd.Process.Pid, err)
+	}
+
+	r.watch(func() (int, *os.ProcessState, error) {
 		err := cmd.Wait()
 		if err == nil {
-			code = ExitCodeOK
-		} else {
-			code = ExitCodeError
-			if exiterr, ok := err.(*exec.ExitError); ok {
-				if status, ok := exiterr.Sys().(syscall.WaitStatus); ok {
-					code = status.ExitStatus()
-				}
-			}
-		}
-
-		// If the child is in the process of killing, do not send a response back
-		// down the exit channel.
-		if r.stopped {
-			return
+			return ExitCodeOK, cmd.ProcessState, nil
 		}
 
-		select {
-		case <-r.stopCh:
-		case exitCh <- code:
+		if exiterr, ok := err.(*exec.ExitError); ok {
+			if status, ok := exiterr.Sys().(syscall.WaitStatus); ok {
+				return status.ExitStatus(), cmd.ProcessState, err
+			}
 		}
-	}()
-
-	r.exitCh = exitCh
-	r.stopCh = make(chan struct{}, 1)
+		return ExitCodeError, cmd.ProcessState, err
+	})
 
 	// If a timeout was given, start the timer to wait for the child to exit
 	if r.Timeout != 0 {
 		select {
-		case code := <-exitCh:
+		case code := <-r.exitCh:
 			if code != 0 {
 				return fmt.Errorf(
 					"command exited with a non-zero exit status:\n"+
@@ -266,10 +854,10 @@ func (r *Process) start() error {
 						"\n"+
 						"This is assumed to be a failure. Please ensure the command\n"+
 						"exits with a zero exit status.",
-					r.command,
+					command,
 				)
 			}
-		case <-time.After(r.Timeout):
+		case <-r.after(r.Timeout):
 			// Force-kill the process
 			if r.exec != nil && r.exec.Process != nil {
 				r.exec.Process.Kill()
@@ -284,13 +872,65 @@ func (r *Process) start() error {
 					"continue. Consider using a process supervisor or utilizing the\n"+
 					"built-in exec mode instead.",
 				r.Timeout,
-				r.command,
+				command,
 			)
 		}
 	}
 	return nil
 }
 
+// watch creates a new exitCh so that previously invoked commands (if any)
+// don't cause us to exit, and starts a goroutine that waits for the current
+// child to end using wait, which returns its exit code.
+func (r *Process) watch(wait func() (int, *os.ProcessState, error)) {
+	// An automatic restart keeps the exit channel of the previous run, so
+	// callers only see the exit once the restarts are exhausted.
+	exitCh := r.restartExitCh
+	if exitCh == nil {
+		exitCh = make(chan int, 1)
+	}
+	r.restartExitCh = nil
+
+	waitCh := make(chan struct{})
+	stopCh := make(chan struct{}, 1)
+	exitStatusCh := make(chan ExitEvent, 1)
+	pid := int(r.pid())
+	r.notify(func(p LifecyclePlugin) { p.OnStart(pid) })
+	r.emit(ProcessEvent{Type: EventStarted, PID: pid})
+	go func() {
+		code, state, err := wait()
+		exitStatusCh <- ExitEvent{ProcessState: state, Err: err, PID: pid, Time: r.now()}
+		r.emit(ProcessEvent{Type: EventExited, PID: pid, ExitError: err})
+		close(waitCh)
+		r.markDown(waitCh)
+		r.pagerDutyDown(waitCh)
+		r.notify(func(p LifecyclePlugin) { p.OnStop(pid, ExitStatus{Code: code}) })
+
+		// If the child is in the process of killing, do not send a response back
+		// down the exit channel.
+		r.stopLock.RLock()
+		stopped := r.stopped
+		r.stopLock.RUnlock()
+		if stopped {
+			return
+		}
+
+		if r.autoRestart(waitCh, exitCh, code) {
+			return
+		}
+
+		select {
+		case <-stopCh:
+		case exitCh <- code:
+		}
+	}()
+
+	r.exitCh = exitCh
+	r.exitStatusCh = exitStatusCh
+	r.waitCh = waitCh
+	r.stopCh = stopCh
+}
+
 func (r *Process) reload() error {
 	select {
 	case <-r.stopCh:
@@ -300,18 +940,42 @@ func (r *Process) reload() error {
 	return r.signal(r.ReloadSignal)
 }
 
-//GetPID return pid current process
+// GetPID return pid current process
 func (r *Process) GetPID() PID {
+	r.RLock()
+	defer r.RUnlock()
+	return r.pid()
+}
+
+// pid returns the PID of the child, 0 when there is none. It must be called
+// with the lock held.
+func (r *Process) pid() PID {
 	if !r.running() {
 		return 0
 	}
 
+	if r.podman != nil {
+		return PID(r.podmanPID)
+	}
+
+	if r.wasmCancel != nil {
+		return 0
+	}
+
 	return PID(r.exec.Process.Pid)
 }
 
-//  check if we already have running process
+// Running reports whether the process has a child, which may have exited on
+// its own since.
+func (r *Process) Running() bool {
+	r.RLock()
+	defer r.RUnlock()
+	return r.running()
+}
+
+// check if we already have running process
 func (r *Process) running() bool {
-	return r.exec != nil && r.exec.Process != nil
+	return r.podman != nil || r.wasmCancel != nil || (r.exec != nil && r.exec.Process != nil)
 }
 
 // Kill sends the kill signal to process and waits for successful termination.
@@ -322,10 +986,18 @@ func (r *Process) running() bool {
 // does not return any errors because it guarantees the process will be dead by
 // the return of the function call.
 func (r *Process) Kill() {
+	if err := r.chaos("Kill"); err != nil {
+		return
+	}
+
 	log.Printf("[INFO] killing process")
 	r.Lock()
 	defer r.Unlock()
+	r.cancelStartTrigger()
 	r.kill()
+	r.removePIDFile()
+	r.haltSLA()
+	r.haltPagerDuty()
 }
 
 func (r *Process) kill() {
@@ -333,12 +1005,25 @@ func (r *Process) kill() {
 		return
 	}
 
-	log.Println("[INFO] kill process ", r.GetPID())
+	log.Println("[INFO] kill process ", r.pid())
+	r.emit(ProcessEvent{Type: EventKilled, PID: int(r.pid()), Signal: r.KillSignal})
+
+	if r.podman != nil {
+		r.killPodman()
+		r.release()
+		return
+	}
+
+	if r.wasmCancel != nil {
+		r.killWASM()
+		r.release()
+		return
+	}
 
 	exited := false
 	process := r.exec.Process
 
-	if r.exec.ProcessState == nil {
+	if !r.exited() {
 		select {
 		case <-r.stopCh:
 		case <-r.randomSplay():
@@ -348,7 +1033,7 @@ func (r *Process) kill() {
 	}
 
 	if r.KillSignal != nil {
-		if err := process.Signal(r.KillSignal); err == nil {
+		if err := r.signalKill(r.KillSignal); err == nil {
 			// Wait a few seconds for it to exit
 			killCh := make(chan struct{}, 1)
 			go func() {
@@ -360,16 +1045,40 @@ func (r *Process) kill() {
 			case <-r.stopCh:
 			case <-killCh:
 				exited = true
-			case <-time.After(r.KillTimeout):
+			case <-r.after(r.KillTimeout):
 			}
 		}
 	}
 
 	if !exited {
-		process.Kill()
+		r.forceKill()
+	}
+
+	// Let the watch of the child report its exit before a new child is
+	// started, up to KillTimeout as output pipes may be held open by its
+	// descendants.
+	select {
+	case <-r.waitCh:
+	case <-r.after(r.KillTimeout):
 	}
 
+	r.release()
+}
+
+// release forgets the killed child and frees the resources held on its behalf.
+func (r *Process) release() {
+	if r.podman != nil {
+		r.removePodman()
+	}
+	r.wasmCancel = nil
+
+	r.removeCgroup()
 	r.exec = nil
+	r.stdinPipe = nil
+	r.removeResolvConf()
+	r.removeTempDir()
+	r.closeMessageQueue()
+	r.releaseLock()
 }
 
 // Stop behavaes almost indetical to Kill except it suppresses feature process
@@ -377,6 +1086,10 @@ func (r *Process) kill() {
 // process from sending its value backup the exit channel. This is usefull when dong
 // graceful sthudown of the application
 func (r *Process) Stop() {
+	if err := r.chaos("Stop"); err != nil {
+		return
+	}
+
 	log.Printf("[INFO] stopped process")
 
 	r.stopLock.Lock()
@@ -387,14 +1100,124 @@ func (r *Process) Stop() {
 		return
 	}
 
-	r.kill()
-	close(r.stopCh)
+	r.Lock()
+	r.cancelStartTrigger()
+	if len(r.ShutdownStages) > 0 {
+		r.shutdownInStages()
+	} else {
+		r.kill()
+	}
+	r.removePIDFile()
+	r.closeStopCh()
+	r.Unlock()
+	r.haltSLA()
+	r.haltPagerDuty()
+
+	r.stopped = true
+}
+
+// closeStopCh closes stopCh, if any, to cut short the waits of the current
+// run. It must be called with the lock held.
+func (r *Process) closeStopCh() {
+	if r.stopCh != nil {
+		close(r.stopCh)
+		r.stopCh = nil
+	}
+}
+
+// StopWithDeadline stops the process like Stop, but escalates through the
+// ReloadSignal, the KillSignal and finally SIGKILL, waiting up to KillTimeout
+// after each signal for the process to exit. If deadline is reached at any
+// point the process is killed at once and ErrStopDeadline is returned, which
+// gives callers an upper bound on how long stopping can take: the deadline,
+// plus up to KillTimeout for the killed process to be reaped.
+func (r *Process) StopWithDeadline(deadline time.Time) error {
+	log.Printf("[INFO] stopping process with deadline %s", deadline)
+
+	r.stopLock.Lock()
+	defer r.stopLock.Unlock()
+
+	if r.stopped {
+		log.Println("[WARN] process already stopped")
+		return nil
+	}
+
+	r.Lock()
+	r.cancelStartTrigger()
+	err := r.stopWithDeadline(deadline)
+	r.removePIDFile()
+	r.closeStopCh()
+	r.Unlock()
+	r.haltSLA()
+	r.haltPagerDuty()
+
 	r.stopped = true
+	return err
+}
+
+func (r *Process) stopWithDeadline(deadline time.Time) error {
+	if !r.running() {
+		return nil
+	}
+
+	defer r.release()
+
+	first := r.ReloadSignal
+	if first == nil {
+		first = r.KillSignal
+	}
+	r.emit(ProcessEvent{Type: EventKilled, PID: int(r.pid()), Signal: first})
+
+	for _, sig := range []os.Signal{r.ReloadSignal, r.KillSignal} {
+		if sig == nil {
+			continue
+		}
+
+		// A deadline already reached would race the exit in the select.
+		if deadline.Sub(r.now()) <= 0 {
+			break
+		}
+
+		log.Printf("[INFO] sending %q to process %d", sig, r.pid())
+		if err := r.signalKill(sig); err != nil {
+			// The process is most likely gone already.
+			continue
+		}
+
+		select {
+		case <-r.waitCh:
+			return nil
+		case <-r.after(r.KillTimeout):
+		case <-r.after(deadline.Sub(r.now())):
+		}
+	}
+
+	if deadline.Sub(r.now()) <= 0 {
+		log.Printf("[WARN] deadline reached, killing process %d", r.pid())
+		r.forceKillAndReap()
+		return ErrStopDeadline
+	}
+
+	r.forceKillAndReap()
+	return nil
+}
+
+// forceKillAndReap kills the process with SIGKILL and waits up to
+// KillTimeout for its watch to reap it, as output pipes may be held open by
+// its descendants.
+func (r *Process) forceKillAndReap() {
+	r.forceKill()
+
+	select {
+	case <-r.waitCh:
+	case <-r.after(r.KillTimeout):
+		log.Printf("[WARN] process %d not reaped within %s of SIGKILL", r.pid(), r.KillTimeout)
+	}
 }
 
 func (r *Process) randomSplay() <-chan time.Time {
 	if r.Splay == 0 {
-		return time.After(0)
+		return r.after(0)
 	}
 
 	ns := r.Splay.Nanoseconds()
@@ -403,7 +1226,7 @@ func (r *Process) randomSplay() <-chan time.Time {
 
 	log.Printf("[DEBUG] (child) waiting %.2fs for random splay", t.Seconds())
 
-	return time.After(t)
+	return r.after(t)
 }
 
 // ExitCh return the current exit channel for this process. this channel may change if the process is restarted, so implementers must
@@ -414,18 +1237,102 @@ func (r *Process) ExitCh() <-chan int {
 	return r.exitCh
 }
 
-//ProcessState 	contains information about an exited process,
+// ExitStatusCh returns the channel receiving the ExitEvent of the current
+// child, once it has exited. Unlike ExitCh, the event is sent whatever the
+// reason of the exit, including Stop and Kill. Like ExitCh, the channel changes
+// when the process is restarted.
+func (r *Process) ExitStatusCh() <-chan ExitEvent {
+	r.RLock()
+	defer r.RUnlock()
+	return r.exitStatusCh
+}
+
+// ProcessState 	contains information about an exited process,
 // available after a call to Wait or Run.
 func (r *Process) ProcessState() *os.ProcessState {
+	r.RLock()
+	defer r.RUnlock()
+
+	if r.exec == nil || !r.exited() {
+		return nil
+	}
 	return r.exec.ProcessState
 }
 
-// Signal sends a signal to the Process, returning any errors that accur.
+// exited reports whether the child has exited and been reaped. It must be
+// called with the lock held.
+func (r *Process) exited() bool {
+	select {
+	case <-r.waitCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// Signal sends a signal to the Process, returning any errors that accur, and
+// ErrNotRunning when there is no child to signal.
 // Sending Interrupt on Windows is not implemented.
 func (r *Process) Signal(s os.Signal) error {
+	if err := r.chaos("Signal"); err != nil {
+		return err
+	}
+
 	log.Printf("[INFO] receiving signal %q", s.String())
+	return r.sendSignal(s)
+}
+
+// UrgentSignal sends a signal to the Process like Signal, but from an OS
+// thread temporarily switched to the SCHED_FIFO real-time policy so delivery
+// is not delayed behind other runnable work on a loaded host. The previous
+// policy is restored once the signal is sent. Raising the policy requires
+// CAP_SYS_NICE and is only supported on Linux; when it fails the signal is
+// still sent with the normal policy.
+func (r *Process) UrgentSignal(s os.Signal) error {
+	if err := r.chaos("Signal"); err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] receiving urgent signal %q", s.String())
+
+	// The signal is sent from a goroutine of its own, as its thread is not
+	// unlocked when the previous policy can't be restored: the runtime then
+	// discards the thread when the goroutine exits, instead of scheduling
+	// other goroutines on it with a real-time policy.
+	errCh := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+
+		restore, err := raisePriority()
+		if err != nil {
+			log.Printf("[WARN] unable to raise scheduling priority: %s", err)
+		}
+
+		errCh <- r.sendSignal(s)
+
+		if restore != nil {
+			if err := restore(); err != nil {
+				log.Printf("[WARN] unable to restore scheduling policy, discarding the thread: %s", err)
+				return
+			}
+		}
+		runtime.UnlockOSThread()
+	}()
+	return <-errCh
+}
+
+// sendSignal notifies the plugins and OnEvent of s and sends it to the child,
+// for Signal and UrgentSignal.
+func (r *Process) sendSignal(s os.Signal) error {
 	r.RLock()
-	defer r.RLock()
+	defer r.RUnlock()
+
+	if !r.running() {
+		return ErrNotRunning
+	}
+
+	r.notify(func(p LifecyclePlugin) { p.OnSignal(s) })
+	r.emit(ProcessEvent{Type: EventSignaled, PID: int(r.pid()), Signal: s})
 	return r.signal(s)
 }
 
@@ -434,5 +1341,21 @@ func (r *Process) signal(s os.Signal) error {
 		return nil
 	}
 
+	if r.podman != nil {
+		return r.signalPodman(s)
+	}
+
+	if r.wasmCancel != nil {
+		return r.signalWASM(s)
+	}
+
+	if ok, err := r.deliverTestSignal(s); ok {
+		return err
+	}
+
+	if r.SignalMode != SignalPID {
+		return signalTree(r.exec.Process.Pid, r.SignalMode, s)
+	}
+
 	return r.exec.Process.Signal(s)
 }
@@ -1,8 +1,16 @@
 package reenvoy
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
@@ -143,8 +151,12 @@ func TestRestart(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Give time for the file to flush
-	time.Sleep(1 * time.Second)
+	// The new child sleeps the whole 2s again before echoing
+	select {
+	case <-c.ExitCh():
+	case <-time.After(5 * time.Second):
+		t.Fatal("process should have exited")
+	}
 
 	expected := "abc\n"
 	assert.Equal(t, expected, out.String())
@@ -298,3 +310,955 @@ func TestKill_noProcess(t *testing.T) {
 	c.KillSignal = syscall.SIGUSR1
 	c.Kill()
 }
+
+func TestTempDir(t *testing.T) {
+	t.Parallel()
+
+	root, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "echo $REENVOY_TMPDIR; while true; do sleep 0.2; done"}
+	c.TempDir = filepath.Join(root, "tmp")
+
+	out := gatedio.NewByteBuffer()
+	c.Stdout, c.StdErr = out, out
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	// For some reason bash doesn't start immediately
+	time.Sleep(fileWaitSleepDelay)
+
+	_, err = os.Stat(c.TempDir)
+	assert.Nil(t, err, "temp dir should exist while the process runs")
+	assert.Equal(t, c.TempDir+"\n", out.String())
+
+	c.Stop()
+
+	_, err = os.Stat(c.TempDir)
+	assert.True(t, os.IsNotExist(err), "temp dir should be removed after stop")
+
+	c.Command = filepath.Join(root, "missing")
+	assert.NotNil(t, c.Start())
+
+	_, err = os.Stat(c.TempDir)
+	assert.True(t, os.IsNotExist(err), "temp dir should be removed when start fails")
+}
+
+func TestLockFile(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	lock := filepath.Join(dir, "envoy.lock")
+
+	first := testProcess(t)
+	first.Command = "bash"
+	first.Args = []string{"-c", "while true; do sleep 0.2; done"}
+	first.LockFile = lock
+
+	second := testProcess(t)
+	second.Command = first.Command
+	second.Args = first.Args
+	second.LockFile = lock
+
+	require.Nil(t, first.Start())
+	defer first.Stop()
+
+	assert.Equal(t, ErrAlreadyRunning, second.Start())
+
+	first.Stop()
+
+	require.Nil(t, second.Start())
+	defer second.Stop()
+}
+
+func TestStopWithDeadline(t *testing.T) {
+	t.Parallel()
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "trap 'exit' SIGINT; while true; do sleep 0.2; done"}
+	c.ReloadSignal = syscall.SIGINT
+	c.KillSignal = syscall.SIGTERM
+	c.KillTimeout = 5 * time.Second
+
+	require.Nil(t, c.Start())
+
+	// For some reason bash doesn't start immediately
+	time.Sleep(fileWaitSleepDelay)
+
+	assert.Nil(t, c.StopWithDeadline(time.Now().Add(5*time.Second)))
+	assert.Equal(t, PID(0), c.GetPID())
+}
+
+func TestStopWithDeadline_deadline(t *testing.T) {
+	t.Parallel()
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "trap '' SIGINT SIGTERM; while true; do sleep 0.2; done"}
+	c.ReloadSignal = syscall.SIGINT
+	c.KillSignal = syscall.SIGTERM
+	c.KillTimeout = 5 * time.Second
+
+	require.Nil(t, c.Start())
+
+	// For some reason bash doesn't start immediately
+	time.Sleep(fileWaitSleepDelay)
+
+	begin := time.Now()
+	assert.Equal(t, ErrStopDeadline, c.StopWithDeadline(begin.Add(200*time.Millisecond)))
+	assert.True(t, time.Since(begin) < time.Second, "stop should not outlive its deadline")
+	assert.Equal(t, PID(0), c.GetPID())
+}
+
+func TestStopWithDeadline_passed(t *testing.T) {
+	t.Parallel()
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "trap 'exit' SIGINT; while true; do sleep 0.2; done"}
+	c.ReloadSignal = syscall.SIGINT
+	c.KillSignal = syscall.SIGTERM
+	c.KillTimeout = 5 * time.Second
+
+	require.Nil(t, c.Start())
+
+	// For some reason bash doesn't start immediately
+	time.Sleep(fileWaitSleepDelay)
+
+	pid := c.GetPID()
+	assert.Equal(t, ErrStopDeadline, c.StopWithDeadline(time.Now().Add(-time.Second)))
+
+	// Killed and reaped by the time StopWithDeadline returns.
+	_, err := os.Stat(fmt.Sprintf("/proc/%d", pid))
+	assert.True(t, os.IsNotExist(err), "process should be reaped")
+}
+
+func TestUrgentSignal(t *testing.T) {
+	t.Parallel()
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "trap 'echo one; exit' SIGUSR1; while true; do sleep 0.2; done"}
+
+	out := gatedio.NewByteBuffer()
+	c.Stdout, c.StdErr = out, out
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	// For some reason bash doesn't start immediately
+	time.Sleep(fileWaitSleepDelay)
+
+	require.Nil(t, c.UrgentSignal(syscall.SIGUSR1))
+
+	// Give time for the file to flush
+	time.Sleep(fileWaitSleepDelay)
+
+	assert.Equal(t, "one\n", out.String())
+}
+
+func TestEnvDiff(t *testing.T) {
+	t.Parallel()
+
+	// env mutates the environment before exec-ing the long running process,
+	// just like a wrapper script would.
+	c := testProcess(t)
+	c.Command = "env"
+	c.Args = []string{"-u", "a", "c=changed", "e=f", "bash", "-c", "while true; do sleep 0.2; done"}
+	c.Env = []string{"a=b", "c=d"}
+
+	_, err := c.SnapshotEnv()
+	assert.Equal(t, ErrNotRunning, err)
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	// Give env time to exec bash
+	time.Sleep(fileWaitSleepDelay)
+
+	snapshot, err := c.SnapshotEnv()
+	require.Nil(t, err)
+	assert.Equal(t, "changed", snapshot["c"])
+
+	assert.Equal(t, []EnvChange{
+		{Type: EnvRemoved, Key: "a", Old: "b"},
+		{Type: EnvModified, Key: "c", Old: "d", New: "changed"},
+		{Type: EnvAdded, Key: "e", New: "f"},
+	}, c.EnvDiff(snapshot))
+}
+
+func TestFDDiff(t *testing.T) {
+	t.Parallel()
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "sleep 0.5; exec 7</dev/null; while true; do sleep 0.2; done"}
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	// For some reason bash doesn't start immediately
+	time.Sleep(fileWaitSleepDelay / 2)
+
+	before, err := c.SnapshotFDs()
+	require.Nil(t, err)
+
+	time.Sleep(fileWaitSleepDelay)
+
+	after, err := c.SnapshotFDs()
+	require.Nil(t, err)
+
+	changes := FDDiff(before, after)
+	require.Len(t, changes, 1)
+	assert.True(t, changes[0].Opened)
+	assert.Equal(t, 7, changes[0].FD)
+	assert.Equal(t, "file", changes[0].Type)
+	assert.Equal(t, "/dev/null", changes[0].Name)
+	assert.Equal(t, int64(0), changes[0].Pos)
+	assert.Equal(t, os.O_RDONLY, changes[0].Flags&syscall.O_ACCMODE)
+}
+
+func TestOutputTimeout(t *testing.T) {
+	t.Parallel()
+
+	timedOut := make(chan struct{}, 1)
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "for i in 1 2 3 4; do echo $i; sleep 0.1; done; while true; do sleep 0.2; done"}
+	c.OutputTimeout = 300 * time.Millisecond
+	c.OnOutputTimeout = func() {
+		timedOut <- struct{}{}
+	}
+
+	out := gatedio.NewByteBuffer()
+	c.Stdout = out
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	select {
+	case <-timedOut:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected output timeout")
+	}
+
+	select {
+	case <-c.ExitCh():
+	case <-time.After(fileWaitSleepDelay):
+		t.Fatal("hung process should have been killed")
+	}
+	assert.Equal(t, "1\n2\n3\n4\n", out.String())
+}
+
+func TestHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "while true; do sleep 0.2; done"}
+	c.HeartbeatFile = filepath.Join(dir, "heartbeat")
+	c.HeartbeatInterval = 50 * time.Millisecond
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	first, err := os.Stat(c.HeartbeatFile)
+	require.Nil(t, err)
+
+	time.Sleep(200 * time.Millisecond)
+
+	last, err := os.Stat(c.HeartbeatFile)
+	require.Nil(t, err)
+	assert.True(t, last.ModTime().After(first.ModTime()), "heartbeat should be touched")
+}
+
+func TestHeartbeat_timeout(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Nobody touches the heartbeat after start.
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "while true; do sleep 0.2; done"}
+	c.HeartbeatFile = filepath.Join(dir, "heartbeat")
+	c.HeartbeatTimeout = 200 * time.Millisecond
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	select {
+	case <-c.ExitCh():
+	case <-time.After(2 * time.Second):
+		t.Fatal("process with a stale heartbeat should have been killed")
+	}
+}
+
+func TestMessageQueue(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", `read msg < "$REENVOY_MSG_IN"; echo "got $msg" > "$REENVOY_MSG_OUT"; sleep 5`}
+	c.MessageQueueDir = dir
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	require.Nil(t, c.SendMessage([]byte("ping")))
+	assert.NotNil(t, c.SendMessage([]byte("two\nlines")))
+
+	msg, err := c.ReceiveMessage()
+	require.Nil(t, err)
+	assert.Equal(t, "got ping", string(msg))
+
+	c.Kill()
+	_, err = c.ReceiveMessage()
+	assert.Equal(t, ErrNoMessageQueue, err)
+}
+
+func TestSpawnRate(t *testing.T) {
+	t.Parallel()
+
+	exceeded := make(chan int, 1)
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "sleep 5"}
+	c.ReloadSignal = nil
+	c.MaxSpawnRate = 2
+	c.SpawnRateMonitorWindow = time.Minute
+	c.OnSpawnRateExceeded = func(count int) { exceeded <- count }
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+	require.Nil(t, c.Restart())
+	assert.False(t, c.SpawnRateExceeded())
+
+	require.Nil(t, c.Restart())
+	assert.True(t, c.SpawnRateExceeded())
+
+	select {
+	case count := <-exceeded:
+		assert.Equal(t, 3, count)
+	case <-time.After(time.Second):
+		t.Fatal("OnSpawnRateExceeded should be called")
+	}
+}
+
+func TestStart_idempotent(t *testing.T) {
+	t.Parallel()
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "sleep 5"}
+	c.IdempotentStart = true
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	pid := c.GetPID()
+	require.Nil(t, c.Start())
+	assert.Equal(t, pid, c.GetPID())
+}
+
+func TestStop_shutdownStages(t *testing.T) {
+	t.Parallel()
+
+	checked := false
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "trap '' TERM; while true; do sleep 0.05; done"}
+	c.ShutdownStages = []ShutdownStage{
+		{
+			Signal:       syscall.SIGTERM,
+			WaitDuration: 10 * time.Second,
+			Condition:    func() bool { checked = true; return true },
+		},
+		{Signal: syscall.SIGUSR1, WaitDuration: 2 * time.Second},
+	}
+
+	require.Nil(t, c.Start())
+	pid := int(c.GetPID())
+
+	// Let bash install the trap.
+	time.Sleep(100 * time.Millisecond)
+
+	begin := time.Now()
+	c.Stop()
+
+	assert.True(t, checked, "condition should end the first stage")
+	assert.True(t, time.Since(begin) < time.Second, "second stage should stop the process")
+	assert.NotNil(t, syscall.Kill(pid, 0))
+}
+
+func TestSignalMode(t *testing.T) {
+	t.Parallel()
+
+	for _, mode := range []SignalDeliveryMode{SignalPGID, SignalAll} {
+		dir, err := ioutil.TempDir("", "reenvoy")
+		require.Nil(t, err)
+		defer os.RemoveAll(dir)
+
+		// The grandchild records its PID; it keeps running when only the
+		// child is signalled.
+		pidFile := filepath.Join(dir, "pid")
+		c := testProcess(t)
+		c.Command = "bash"
+		c.Args = []string{"-c", fmt.Sprintf("sleep 10 & echo $! > %s; wait", pidFile)}
+		c.SignalMode = mode
+
+		require.Nil(t, c.Start())
+		time.Sleep(fileWaitSleepDelay)
+
+		data, err := ioutil.ReadFile(pidFile)
+		require.Nil(t, err)
+		var pid int
+		_, err = fmt.Sscanf(string(data), "%d", &pid)
+		require.Nil(t, err)
+
+		require.Nil(t, c.Signal(syscall.SIGTERM))
+		<-c.ExitCh()
+
+		time.Sleep(100 * time.Millisecond)
+		assert.False(t, processAlive(pid), "grandchild should be signalled in mode %d", mode)
+		c.Stop()
+	}
+}
+
+func TestKillProcessGroup(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	pidFile := filepath.Join(dir, "pid")
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", fmt.Sprintf("sleep 10 & echo $! > %s; wait", pidFile)}
+	c.KillProcessGroup = true
+
+	require.Nil(t, c.Start())
+	time.Sleep(fileWaitSleepDelay)
+
+	data, err := ioutil.ReadFile(pidFile)
+	require.Nil(t, err)
+	var pid int
+	_, err = fmt.Sscanf(string(data), "%d", &pid)
+	require.Nil(t, err)
+
+	c.Kill()
+
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, processAlive(pid), "background sleep should be killed with the group")
+}
+
+// processAlive reports whether pid is running, not counting zombies left
+// unreaped by an init that doesn't wait for orphans.
+func processAlive(pid int) bool {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+
+	fields := strings.Fields(string(data[strings.LastIndexByte(string(data), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestBinaryWatcher(t *testing.T) {
+	binaryPollInterval = 20 * time.Millisecond
+
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	binary := filepath.Join(dir, "server")
+	require.Nil(t, ioutil.WriteFile(binary, []byte("#!/bin/bash\nsleep 5\n"), 0755))
+
+	c := testProcess(t)
+	c.Command = binary
+	c.Args = nil
+	c.BinaryWatcher = true
+	c.BinaryUpdateDebounce = 100 * time.Millisecond
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+	pid := c.GetPID()
+
+	require.Nil(t, ioutil.WriteFile(binary, []byte("#!/bin/bash\nsleep 10\n"), 0755))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		c.RLock()
+		newPID := c.GetPID()
+		c.RUnlock()
+		if newPID != 0 && newPID != pid {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("process should be restarted with the new binary")
+}
+
+type testZoneCoordinator struct {
+	mu     sync.Mutex
+	holder string
+	events []string
+}
+
+func (c *testZoneCoordinator) BeginRestart(ctx context.Context, zone string) error {
+	for {
+		c.mu.Lock()
+		if c.holder == "" {
+			c.holder = zone
+			c.events = append(c.events, "begin "+zone)
+			c.mu.Unlock()
+			return nil
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (c *testZoneCoordinator) EndRestart(zone string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.holder = ""
+	c.events = append(c.events, "end "+zone)
+	return nil
+}
+
+func TestRestart_zoneAware(t *testing.T) {
+	t.Parallel()
+
+	coord := &testZoneCoordinator{holder: "us-east-1b"}
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "sleep 5"}
+	c.ReloadSignal = nil
+	c.ZoneAwareRestart = true
+	c.ZoneID = "us-east-1a"
+	c.ZoneRestartTimeout = time.Second
+	c.ZoneCoordinator = coord
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		coord.EndRestart("us-east-1b")
+	}()
+
+	begin := time.Now()
+	require.Nil(t, c.Restart())
+	assert.True(t, time.Since(begin) >= 100*time.Millisecond, "restart should wait for the other zone")
+
+	coord.mu.Lock()
+	defer coord.mu.Unlock()
+	assert.Equal(t, []string{"end us-east-1b", "begin us-east-1a", "end us-east-1a"}, coord.events)
+}
+
+func TestDNS(t *testing.T) {
+	t.Parallel()
+
+	out := new(bytes.Buffer)
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", `cat "$RESOLV_CONF"; echo "$LOCALDOMAIN"`}
+	c.Stdout = out
+	c.DNSServers = []string{"10.0.0.2", "10.0.0.3"}
+	c.DNSSearchDomains = []string{"svc.local", "local"}
+
+	require.Nil(t, c.Start())
+	<-c.ExitCh()
+
+	assert.Equal(t, "nameserver 10.0.0.2\nnameserver 10.0.0.3\nsearch svc.local local\nsvc.local local\n", out.String())
+}
+
+func TestDNS_invalidServer(t *testing.T) {
+	t.Parallel()
+
+	c := testProcess(t)
+	c.DNSServers = []string{"dns.example.com"}
+	assert.NotNil(t, c.Start())
+}
+
+func TestDumpTree(t *testing.T) {
+	t.Parallel()
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "sleep 5 & sleep 6 & wait"}
+
+	_, err := c.DumpTree()
+	assert.Equal(t, ErrNotRunning, err)
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	tree, err := c.DumpTree()
+	require.Nil(t, err)
+
+	lines := strings.Split(strings.TrimSpace(tree), "\n")
+	require.Len(t, lines, 3, tree)
+	assert.Contains(t, lines[0], fmt.Sprintf("%d bash (cpu ", c.GetPID()))
+	assert.Contains(t, lines[1], "├─ ")
+	assert.Contains(t, lines[1], " sleep ")
+	assert.Contains(t, lines[2], "└─ ")
+}
+
+func TestAutoRestart(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	count := filepath.Join(dir, "count")
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "echo x >> " + count + "; exit 3"}
+	c.MaxRestarts = 2
+	c.RestartDelay = 50 * time.Millisecond
+	c.RestartBackoffFactor = 2
+
+	begin := time.Now()
+	require.Nil(t, c.Start())
+
+	// The exit channel of the first run only fires once restarts are
+	// exhausted.
+	select {
+	case code := <-c.ExitCh():
+		assert.Equal(t, 3, code)
+	case <-time.After(2 * time.Second):
+		t.Fatal("process should exit after the restarts")
+	}
+
+	assert.True(t, time.Since(begin) >= 150*time.Millisecond, "restarts should back off")
+	assert.Equal(t, 2, c.RestartCount())
+
+	data, err := ioutil.ReadFile(count)
+	require.Nil(t, err)
+	assert.Equal(t, "x\nx\nx\n", string(data))
+}
+
+func TestAutoRestart_kill(t *testing.T) {
+	t.Parallel()
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "sleep 5"}
+	c.MaxRestarts = -1
+
+	require.Nil(t, c.Start())
+	exitCh := c.ExitCh()
+	c.Kill()
+
+	select {
+	case <-exitCh:
+	case <-time.After(time.Second):
+		t.Fatal("killed process should not be restarted")
+	}
+	assert.Equal(t, 0, c.RestartCount())
+}
+
+func TestAutoRestart_stop(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	count := filepath.Join(dir, "count")
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "echo x >> " + count + "; exit 3"}
+	c.MaxRestarts = -1
+	c.RestartDelay = 500 * time.Millisecond
+
+	require.Nil(t, c.Start())
+	for deadline := time.Now().Add(time.Second); c.RestartCount() == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("an automatic restart should be pending")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Stop cuts the restart delay short and nothing is restarted after it.
+	begin := time.Now()
+	c.Stop()
+	assert.True(t, time.Since(begin) < 250*time.Millisecond, "stop should not wait for the restart delay")
+
+	time.Sleep(700 * time.Millisecond)
+	data, err := ioutil.ReadFile(count)
+	require.Nil(t, err)
+	assert.Equal(t, "x\n", string(data))
+}
+
+func TestAutoRestart_window(t *testing.T) {
+	t.Parallel()
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "sleep 0.1; exit 1"}
+	c.MaxRestarts = 1
+	c.RestartWindow = 50 * time.Millisecond
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	// Every run outlives the window, so the count never reaches the limit.
+	select {
+	case <-c.ExitCh():
+		t.Fatal("restart count should be reset by the window")
+	case <-time.After(500 * time.Millisecond):
+	}
+	assert.Equal(t, 1, c.RestartCount())
+}
+
+func TestExitStatusCh(t *testing.T) {
+	t.Parallel()
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "exit 2"}
+
+	begin := time.Now()
+	require.Nil(t, c.Start())
+	pid := int(c.GetPID())
+	exitStatusCh := c.ExitStatusCh()
+
+	// Drain the exit code first; the event must not depend on it.
+	<-c.ExitCh()
+
+	select {
+	case e := <-exitStatusCh:
+		require.NotNil(t, e.ProcessState)
+		assert.Equal(t, 2, e.ProcessState.ExitCode())
+		assert.NotNil(t, e.Err)
+		assert.Equal(t, pid, e.PID)
+		assert.True(t, e.Time.After(begin))
+	case <-time.After(time.Second):
+		t.Fatal("exit event should be sent")
+	}
+
+	select {
+	case <-exitStatusCh:
+		t.Fatal("exit event should be sent once")
+	default:
+	}
+}
+
+func TestStartupProbe(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	ready := filepath.Join(dir, "ready")
+	probe := func() error {
+		_, err := os.Stat(ready)
+		return err
+	}
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "sleep 0.2; touch " + ready + "; sleep 10"}
+	c.ReloadSignal = nil
+	c.StartupProbe = probe
+	c.StartupTimeout = 2 * time.Second
+	c.StartupPollInterval = 20 * time.Millisecond
+	defer c.Stop()
+
+	require.Nil(t, c.Start())
+	assert.Nil(t, probe(), "start should wait for the probe")
+
+	// The new child is probed on restart as well.
+	require.Nil(t, os.Remove(ready))
+	require.Nil(t, c.Restart())
+	assert.Nil(t, probe(), "restart should wait for the probe")
+}
+
+func TestStartupProbe_failure(t *testing.T) {
+	t.Parallel()
+
+	errNotReady := errors.New("not ready")
+	probe := func() error { return errNotReady }
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "sleep 10"}
+	c.StartupProbe = probe
+	c.StartupTimeout = 100 * time.Millisecond
+	c.StartupPollInterval = 20 * time.Millisecond
+	c.IdempotentStart = true
+	defer c.Stop()
+
+	err := c.Start()
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "not ready")
+	assert.True(t, errors.Is(err, errNotReady))
+
+	// The child is killed, so starting again probes a new one.
+	assert.Equal(t, PID(0), c.GetPID())
+	assert.NotNil(t, c.Start())
+
+	// A child crashing on start fails before the timeout.
+	crash := testProcess(t)
+	crash.Command = "bash"
+	crash.Args = []string{"-c", "exit 1"}
+	crash.StartupProbe = probe
+	crash.StartupTimeout = 5 * time.Second
+
+	begin := time.Now()
+	err = crash.Start()
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "exited")
+	assert.True(t, time.Since(begin) < time.Second)
+}
+
+func TestPIDFile(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	readPID := func(file string) int {
+		data, err := ioutil.ReadFile(file)
+		require.Nil(t, err)
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		require.Nil(t, err)
+		return pid
+	}
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "sleep 10"}
+	c.ReloadSignal = nil
+	c.PIDFile = filepath.Join(dir, "reenvoy.pid")
+
+	require.Nil(t, c.Start())
+	assert.Equal(t, int(c.GetPID()), readPID(c.PIDFile))
+
+	oldPID := int(c.GetPID())
+	require.Nil(t, c.Restart())
+	assert.NotEqual(t, oldPID, int(c.GetPID()))
+	assert.Equal(t, int(c.GetPID()), readPID(c.PIDFile))
+
+	c.Stop()
+	_, err = os.Stat(c.PIDFile)
+	assert.True(t, os.IsNotExist(err), "pid file should be removed on stop")
+
+	// Start fails when the file can't be written.
+	missing := testProcess(t)
+	missing.Command = "bash"
+	missing.Args = []string{"-c", "sleep 10"}
+	missing.PIDFile = filepath.Join(dir, "missing", "reenvoy.pid")
+	assert.NotNil(t, missing.Start())
+	assert.Equal(t, PID(0), missing.GetPID())
+}
+
+func TestReloadTimeout(t *testing.T) {
+	t.Parallel()
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "trap '' USR1; sleep 10"}
+	c.ReloadSignal = syscall.SIGUSR1
+	c.ReloadTimeout = 100 * time.Millisecond
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	// Let bash install the trap.
+	time.Sleep(100 * time.Millisecond)
+
+	oldPID := c.GetPID()
+	err := c.Restart()
+	assert.True(t, errors.Is(err, ErrReloadTimeout), "unexpected error %v", err)
+	assert.NotEqual(t, oldPID, c.GetPID())
+
+	// An acknowledged reload keeps the process.
+	c.ReloadTimeout = time.Second
+	c.ReloadAckProbe = func() error { return nil }
+	oldPID = c.GetPID()
+	time.Sleep(100 * time.Millisecond)
+	assert.Nil(t, c.Restart())
+	assert.Equal(t, oldPID, c.GetPID())
+}
+
+func TestOnEvent(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var events []ProcessEvent
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "sleep 10"}
+	c.ReloadSignal = nil
+	c.OnEvent = func(e ProcessEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}
+
+	require.Nil(t, c.Start())
+	first := int(c.GetPID())
+	require.Nil(t, c.Restart())
+	second := int(c.GetPID())
+	c.Stop()
+
+	// Give the watch of the last child time to see it exit.
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := []struct {
+		typ ProcessEventType
+		pid int
+	}{
+		{EventStarted, first},
+		{EventKilled, first},
+		{EventExited, first},
+		{EventStarted, second},
+		{EventRestarted, second},
+		{EventKilled, second},
+		{EventExited, second},
+	}
+	require.Equal(t, len(expected), len(events), "%+v", events)
+	for i, e := range expected {
+		assert.Equal(t, e.typ, events[i].Type, "event %d", i)
+		assert.Equal(t, e.pid, events[i].PID, "event %d", i)
+		assert.False(t, events[i].Timestamp.IsZero())
+	}
+	assert.Equal(t, os.Kill, events[1].Signal)
+	assert.NotNil(t, events[2].ExitError)
+}
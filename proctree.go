@@ -0,0 +1,15 @@
+package reenvoy
+
+// DumpTree returns a pstree-like view of the child and all of its
+// descendants, with the PID, command, CPU time and resident memory of each,
+// to help debugging runaway subprocesses. It is only supported on Linux.
+func (r *Process) DumpTree() (string, error) {
+	r.RLock()
+	pid := int(r.pid())
+	r.RUnlock()
+
+	if pid == 0 {
+		return "", ErrNotRunning
+	}
+	return dumpTree(pid)
+}
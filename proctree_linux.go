@@ -0,0 +1,111 @@
+//go:build linux
+// +build linux
+
+package reenvoy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// clockTicks is the USER_HZ unit of the CPU times in /proc/PID/stat, which
+// is 100 on all mainstream architectures.
+const clockTicks = 100
+
+// dumpTree formats the process tree rooted at pid.
+func dumpTree(pid int) (string, error) {
+	children, err := processChildren()
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	var walk func(pid int, prefix, branch, indent string)
+	walk = func(pid int, prefix, branch, indent string) {
+		fmt.Fprintf(&buf, "%s%s%s\n", prefix, branch, describeProcess(pid))
+
+		kids := children[pid]
+		sort.Ints(kids)
+		for i, kid := range kids {
+			if i == len(kids)-1 {
+				walk(kid, prefix+indent, "└─ ", "   ")
+			} else {
+				walk(kid, prefix+indent, "├─ ", "│  ")
+			}
+		}
+	}
+	walk(pid, "", "", "")
+
+	return buf.String(), nil
+}
+
+// describeProcess returns the PID, name, CPU time and resident memory of
+// pid.
+func describeProcess(pid int) string {
+	name, rss := "?", "?"
+	if data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid)); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			key, value, ok := cutString(scanner.Text(), ":")
+			if !ok {
+				continue
+			}
+
+			value = strings.TrimSpace(value)
+			switch key {
+			case "Name":
+				name = value
+			case "VmRSS":
+				rss = value
+			}
+		}
+	}
+
+	cpu := "?"
+	if data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid)); err == nil {
+		if ticks, ok := parseCPUTicks(string(data)); ok {
+			cpu = fmt.Sprintf("%.2fs", float64(ticks)/clockTicks)
+		}
+	}
+
+	return fmt.Sprintf("%d %s (cpu %s, rss %s)", pid, name, cpu, rss)
+}
+
+// parseCPUTicks returns the user and system time from the content of
+// /proc/PID/stat, in clock ticks.
+func parseCPUTicks(stat string) (uint64, bool) {
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0, false
+	}
+
+	// utime and stime are the 12th and 13th fields after the command name.
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 13 {
+		return 0, false
+	}
+
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return utime + stime, true
+}
+
+// cutString slices s around the first instance of sep.
+func cutString(s, sep string) (string, string, bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package reenvoy
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// dumpTree is only supported on Linux.
+func dumpTree(pid int) (string, error) {
+	return "", fmt.Errorf("process tree is not supported on %s", runtime.GOOS)
+}
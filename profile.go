@@ -0,0 +1,276 @@
+package reenvoy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// perfCommand is the profiler run by ContinuousProfile.
+	perfCommand = "perf"
+
+	// defaultProfileInterval is the ProfileInterval used when none is set.
+	defaultProfileInterval = time.Minute
+)
+
+const (
+	flameGraphWidth       = 1200
+	flameGraphFrameHeight = 16
+)
+
+// FlameGraph returns the SVG flame graph of the last profile captured by
+// ContinuousProfile, or ErrNoFlameGraph when none was captured yet.
+func (r *Process) FlameGraph() (io.Reader, error) {
+	r.RLock()
+	path := r.flameGraph
+	r.RUnlock()
+
+	if path == "" {
+		return nil, ErrNoFlameGraph
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// watchProfile profiles the process with perf, run as command, for
+// ProfileInterval at a time, back to back, saving a flame graph of each
+// profile in ProfileOutputDir. It returns when the process exits.
+func (r *Process) watchProfile(command string, pid int, waitCh <-chan struct{}) {
+	if runtime.GOOS != "linux" {
+		log.Printf("[WARN] continuous profiling is only supported on linux")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-waitCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	interval := r.ProfileInterval
+	if interval <= 0 {
+		interval = defaultProfileInterval
+	}
+
+	dir := r.ProfileOutputDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	for ctx.Err() == nil {
+		path, err := captureFlameGraph(ctx, command, dir, pid, interval)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("[WARN] failed to profile process %d: %s", pid, err)
+			}
+			return
+		}
+
+		log.Printf("[DEBUG] saved flame graph of process %d to %s", pid, path)
+		r.Lock()
+		r.flameGraph = path
+		r.Unlock()
+	}
+}
+
+// captureFlameGraph records the stacks of pid for d with perf, run as
+// command, and renders them as a flame graph in dir, returning the path of
+// the SVG.
+func captureFlameGraph(ctx context.Context, command, dir string, pid int, d time.Duration) (string, error) {
+	name := fmt.Sprintf("reenvoy-%d-%d", pid, time.Now().Unix())
+	data := filepath.Join(dir, name+".perf.data")
+	defer os.Remove(data)
+
+	seconds := strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+	record := exec.CommandContext(ctx, command, "record", "-q", "-F", "99", "-g",
+		"-p", strconv.Itoa(pid), "-o", data, "--", "sleep", seconds)
+	if out, err := record.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("perf record err: %s: %s", err, bytes.TrimSpace(out))
+	}
+
+	script, err := exec.CommandContext(ctx, command, "script", "-i", data).Output()
+	if err != nil {
+		return "", fmt.Errorf("perf script err: %s", err)
+	}
+
+	folded, err := foldPerfScript(bytes.NewReader(script))
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, name+".svg")
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if err := renderFlameGraph(f, folded); err != nil {
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// foldPerfScript counts the stacks in the output of perf script, each stack
+// being folded into its frames joined by ";", the command first and the leaf
+// last.
+func foldPerfScript(rd io.Reader) (map[string]int, error) {
+	folded := make(map[string]int)
+
+	var comm string
+	var frames []string
+	flush := func() {
+		if comm == "" {
+			return
+		}
+		stack := []string{comm}
+		for i := len(frames) - 1; i >= 0; i-- {
+			stack = append(stack, frames[i])
+		}
+		folded[strings.Join(stack, ";")]++
+		comm, frames = "", nil
+	}
+
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+		case line[0] == ' ' || line[0] == '\t':
+			// "\t7f0c2d1e4b30 do_work+0x10 (/usr/bin/app)"
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			sym := fields[1]
+			if i := strings.LastIndex(sym, "+0x"); i > 0 {
+				sym = sym[:i]
+			}
+			frames = append(frames, sym)
+		default:
+			flush()
+			comm = strings.Fields(line)[0]
+		}
+	}
+	flush()
+
+	return folded, scanner.Err()
+}
+
+// flameNode is a frame of a flame graph with the number of samples in which
+// it appears.
+type flameNode struct {
+	name     string
+	samples  int
+	children map[string]*flameNode
+}
+
+func (n *flameNode) child(name string) *flameNode {
+	if n.children == nil {
+		n.children = make(map[string]*flameNode)
+	}
+	c, ok := n.children[name]
+	if !ok {
+		c = &flameNode{name: name}
+		n.children[name] = c
+	}
+	return c
+}
+
+// renderFlameGraph writes the folded stacks as an SVG flame graph, the roots
+// at the bottom and the children of each frame sorted by name.
+func renderFlameGraph(w io.Writer, folded map[string]int) error {
+	root := &flameNode{name: "all"}
+	depth := 0
+	for stack, count := range folded {
+		frames := strings.Split(stack, ";")
+		if len(frames) > depth {
+			depth = len(frames)
+		}
+
+		root.samples += count
+		n := root
+		for _, frame := range frames {
+			n = n.child(frame)
+			n.samples += count
+		}
+	}
+
+	height := (depth + 1) * flameGraphFrameHeight
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="monospace" font-size="11">`+"\n",
+		flameGraphWidth, height)
+
+	var draw func(n *flameNode, x float64, level int)
+	draw = func(n *flameNode, x float64, level int) {
+		width := float64(flameGraphWidth)
+		if root.samples > 0 {
+			width = width * float64(n.samples) / float64(root.samples)
+		}
+		y := height - (level+1)*flameGraphFrameHeight
+
+		name := html.EscapeString(n.name)
+		fmt.Fprintf(bw, `<g><title>%s (%d samples)</title>`, name, n.samples)
+		fmt.Fprintf(bw, `<rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%s"/>`,
+			x, y, width, flameGraphFrameHeight-1, flameColor(n.name))
+		if chars := int(width / 7); chars >= 3 {
+			label := n.name
+			if len(label) > chars {
+				label = label[:chars-2] + ".."
+			}
+			fmt.Fprintf(bw, `<text x="%.1f" y="%d">%s</text>`, x+2, y+flameGraphFrameHeight-4, html.EscapeString(label))
+		}
+		bw.WriteString("</g>\n")
+
+		names := make([]string, 0, len(n.children))
+		for name := range n.children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			c := n.children[name]
+			draw(c, x, level+1)
+			x += float64(flameGraphWidth) * float64(c.samples) / float64(root.samples)
+		}
+	}
+	draw(root, 0, 0)
+
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+// flameColor returns a warm color derived from name, so a frame keeps its
+// color across graphs.
+func flameColor(name string) string {
+	var h uint32 = 2166136261
+	for i := 0; i < len(name); i++ {
+		h = (h ^ uint32(name[i])) * 16777619
+	}
+	return fmt.Sprintf("rgb(%d,%d,%d)", 205+h%50, 80+(h>>8)%130, (h>>16)%55)
+}
@@ -0,0 +1,96 @@
+package reenvoy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPerfScript = `envoy  1234 [000] 100.000001:   10101010 cpu-clock:
+	    55d1c0a0 do_work+0x10 (/usr/bin/envoy)
+	    55d1c0b0 main+0x20 (/usr/bin/envoy)
+
+envoy  1234 [000] 100.010001:   10101010 cpu-clock:
+	    55d1c0a0 do_work+0x18 (/usr/bin/envoy)
+	    55d1c0b0 main+0x20 (/usr/bin/envoy)
+
+envoy  1234 [001] 100.020001:   10101010 cpu-clock:
+	    55d1c0c0 idle<loop> (/usr/bin/envoy)
+	    55d1c0b0 main+0x20 (/usr/bin/envoy)
+`
+
+func TestFoldPerfScript(t *testing.T) {
+	t.Parallel()
+
+	folded, err := foldPerfScript(strings.NewReader(testPerfScript))
+	require.Nil(t, err)
+	assert.Equal(t, map[string]int{
+		"envoy;main;do_work":    2,
+		"envoy;main;idle<loop>": 1,
+	}, folded)
+
+	var b bytes.Buffer
+	require.Nil(t, renderFlameGraph(&b, folded))
+	assert.True(t, strings.HasPrefix(b.String(), "<svg "))
+	assert.Contains(t, b.String(), "<title>do_work (2 samples)</title>")
+	assert.Contains(t, b.String(), "<title>idle&lt;loop&gt; (1 samples)</title>")
+}
+
+func TestContinuousProfile(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("continuous profiling is only supported on linux")
+	}
+
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// A fake perf creating the data file and printing a known script.
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "script"), []byte(testPerfScript), 0644))
+	perf := filepath.Join(dir, "perf")
+	require.Nil(t, ioutil.WriteFile(perf, []byte(`#!/bin/sh
+case "$1" in
+record) while [ "$1" != "-o" ]; do shift; done; touch "$2"; sleep 0.1 ;;
+script) cat "`+filepath.Join(dir, "script")+`" ;;
+esac
+`), 0755))
+
+	defer func(command string) { perfCommand = command }(perfCommand)
+	perfCommand = perf
+
+	c := testProcess(t)
+	c.Command = "sleep"
+	c.Args = []string{"5"}
+	c.ContinuousProfile = true
+	c.ProfileInterval = 100 * time.Millisecond
+	c.ProfileOutputDir = dir
+
+	_, err = c.FlameGraph()
+	assert.Equal(t, ErrNoFlameGraph, err)
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		graph, err := c.FlameGraph()
+		if err == nil {
+			svg, err := ioutil.ReadAll(graph)
+			require.Nil(t, err)
+			assert.Contains(t, string(svg), "do_work")
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no flame graph was captured")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,124 @@
+package reenvoy
+
+import (
+	"math/rand"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+// propertyConfig is a valid configuration of a Process, generated by
+// testing/quick.
+type propertyConfig struct {
+	Splay            time.Duration
+	KillTimeout      time.Duration
+	ReloadSignal     os.Signal
+	KillSignal       os.Signal
+	SignalMode       SignalDeliveryMode
+	KillProcessGroup bool
+}
+
+func (propertyConfig) Generate(rnd *rand.Rand, size int) reflect.Value {
+	signals := []os.Signal{nil, syscall.SIGHUP, syscall.SIGTERM, os.Kill}
+	return reflect.ValueOf(propertyConfig{
+		Splay:            time.Duration(rnd.Intn(20)) * time.Millisecond,
+		KillTimeout:      time.Duration(rnd.Intn(200)) * time.Millisecond,
+		ReloadSignal:     signals[rnd.Intn(len(signals))],
+		KillSignal:       signals[1+rnd.Intn(len(signals)-1)],
+		SignalMode:       SignalDeliveryMode(rnd.Intn(3)),
+		KillProcessGroup: rnd.Intn(2) == 0,
+	})
+}
+
+func (c propertyConfig) process() *Process {
+	return &Process{
+		Command:          "sleep",
+		Args:             []string{"10"},
+		Splay:            c.Splay,
+		KillTimeout:      c.KillTimeout,
+		ReloadSignal:     c.ReloadSignal,
+		KillSignal:       c.KillSignal,
+		SignalMode:       c.SignalMode,
+		KillProcessGroup: c.KillProcessGroup,
+	}
+}
+
+// checkProperty checks that f holds for generated configurations.
+func checkProperty(t *testing.T, f func(propertyConfig) bool) {
+	if err := quick.Check(f, &quick.Config{MaxCount: 10}); err != nil {
+		t.Error(err)
+	}
+}
+
+// within reports whether fn returns before timeout.
+func within(timeout time.Duration, fn func()) bool {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func TestProperty_pidWhileRunning(t *testing.T) {
+	t.Parallel()
+
+	checkProperty(t, func(c propertyConfig) bool {
+		p := c.process()
+		if err := p.Start(); err != nil {
+			return false
+		}
+		defer p.Stop()
+		return p.GetPID() > 0
+	})
+}
+
+func TestProperty_stopAfterStart(t *testing.T) {
+	t.Parallel()
+
+	checkProperty(t, func(c propertyConfig) bool {
+		p := c.process()
+		if err := p.Start(); err != nil {
+			return false
+		}
+		return within(5*time.Second, p.Stop) && p.GetPID() == 0
+	})
+}
+
+func TestProperty_restartNeverDeadlocks(t *testing.T) {
+	t.Parallel()
+
+	checkProperty(t, func(c propertyConfig) bool {
+		p := c.process()
+		if err := p.Start(); err != nil {
+			return false
+		}
+		defer p.Stop()
+
+		// A signal beforehand must not leave the process locked.
+		p.Signal(syscall.SIGCONT)
+		return within(5*time.Second, func() { p.Restart() })
+	})
+}
+
+func TestProperty_signalAfterStop(t *testing.T) {
+	t.Parallel()
+
+	checkProperty(t, func(c propertyConfig) bool {
+		p := c.process()
+		if err := p.Start(); err != nil {
+			return false
+		}
+		p.Stop()
+		return p.Signal(syscall.SIGCONT) != nil
+	})
+}
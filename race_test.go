@@ -0,0 +1,74 @@
+package reenvoy
+
+import (
+	"math/rand"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestRace drives the same Process from many goroutines at once. It is meant
+// to be run with go test -race, which fails it on any data race.
+func TestRace(t *testing.T) {
+	t.Parallel()
+
+	p := &Process{
+		Command:         "sleep",
+		Args:            []string{"10"},
+		KillSignal:      syscall.SIGTERM,
+		KillTimeout:     100 * time.Millisecond,
+		IdempotentStart: true,
+	}
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	ops := []func(){
+		func() { p.Start() },
+		func() { p.Restart() },
+		func() { p.Signal(syscall.SIGCONT) },
+		func() { p.Kill() },
+		func() { p.GetPID() },
+		func() { p.Running() },
+		func() { p.ExitCh() },
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+
+			rnd := rand.New(rand.NewSource(seed))
+			for j := 0; j < 20; j++ {
+				ops[rnd.Intn(len(ops))]()
+			}
+		}(int64(i))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("concurrent operations deadlocked")
+	}
+
+	// Stop races with everything else once the others are done.
+	var stops sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		stops.Add(1)
+		go func() {
+			defer stops.Done()
+			p.Stop()
+			p.GetPID()
+		}()
+	}
+	stops.Wait()
+}
@@ -19,7 +19,7 @@ func TestReenvoy_Start(t *testing.T) {
 		KillTimeout:  2 * time.Second,
 	}
 
-	proc, err := reenvoy.SpawnProcess(opts)
+	proc, err := reenvoy.SpawnProcess(opts, 0)
 	require.Nil(t, err, "start reenvoy")
 	require.NotEmpty(t, proc.GetPID())
 }
@@ -0,0 +1,56 @@
+package reenvoy
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// reloadPollInterval is how often the ReloadAckProbe is called while waiting
+// for a reload to be acknowledged.
+var reloadPollInterval = 100 * time.Millisecond
+
+// waitReload waits up to ReloadTimeout for the child behind waitCh to exit or
+// for the ReloadAckProbe to succeed, and reports whether either happened.
+func (r *Process) waitReload(waitCh <-chan struct{}) bool {
+	timeout := r.after(r.ReloadTimeout)
+
+	var tick <-chan time.Time
+	if r.ReloadAckProbe != nil {
+		ticker := time.NewTicker(reloadPollInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-waitCh:
+			return true
+		case <-timeout:
+			return false
+		case <-tick:
+			if err := r.ReloadAckProbe(); err == nil {
+				return true
+			}
+		}
+	}
+}
+
+// restartAfterReloadTimeout replaces a child which did not acknowledge its
+// reload in time, returning an error wrapping ErrReloadTimeout.
+func (r *Process) restartAfterReloadTimeout() error {
+	log.Printf("[WARN] reload not acknowledged within %s, restarting process", r.ReloadTimeout)
+
+	r.Lock()
+	err := r.restart()
+	waitCh := r.waitCh
+	r.Unlock()
+
+	if err == nil {
+		err = r.waitStartup(waitCh)
+	}
+	if err != nil {
+		return fmt.Errorf("%w, restart err: %s", ErrReloadTimeout, err)
+	}
+	return ErrReloadTimeout
+}
@@ -0,0 +1,52 @@
+//go:build linux
+// +build linux
+
+package reenvoy
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const schedFIFO = 1
+
+type schedParam struct {
+	priority int32
+}
+
+// raisePriority switches the calling OS thread to SCHED_FIFO with the
+// highest priority allowed and returns a function restoring the previous
+// policy. The caller must have locked the goroutine to its thread, and keep
+// it locked when restoring fails so the thread exits with the goroutine.
+func raisePriority() (func() error, error) {
+	policy, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETSCHEDULER, 0, 0, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	var old schedParam
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETPARAM, 0, uintptr(unsafe.Pointer(&old)), 0); errno != 0 {
+		return nil, errno
+	}
+
+	max, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GET_PRIORITY_MAX, schedFIFO, 0, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	if err := setScheduler(schedFIFO, schedParam{priority: int32(max)}); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		return setScheduler(int(policy), old)
+	}, nil
+}
+
+func setScheduler(policy int, param schedParam) error {
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETSCHEDULER, 0, uintptr(policy), uintptr(unsafe.Pointer(&param)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
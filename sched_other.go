@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package reenvoy
+
+import "errors"
+
+// raisePriority is only implemented on Linux.
+func raisePriority() (func() error, error) {
+	return nil, errors.New("real-time scheduling is not supported on this platform")
+}
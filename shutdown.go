@@ -0,0 +1,87 @@
+package reenvoy
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// shutdownPollInterval is how often the Condition of a ShutdownStage is
+// evaluated.
+const shutdownPollInterval = 50 * time.Millisecond
+
+// ShutdownStage is a step of the shutdown sequence run by Stop when
+// ShutdownStages is set.
+type ShutdownStage struct {
+	// Signal is sent to the process when the stage begins. A nil Signal only
+	// waits.
+	Signal os.Signal
+
+	// WaitDuration is how long to wait for the process to exit before moving
+	// on to the next stage.
+	WaitDuration time.Duration
+
+	// Condition, when set, is polled while waiting and moves on to the next
+	// stage as soon as it returns true.
+	Condition func() bool
+}
+
+// shutdownInStages runs ShutdownStages until the process exits, and kills the
+// process if it outlives all of them. It must be called with the lock held.
+func (r *Process) shutdownInStages() {
+	if !r.running() {
+		return
+	}
+
+	defer r.release()
+
+	var first os.Signal
+	for _, stage := range r.ShutdownStages {
+		if first = stage.Signal; first != nil {
+			break
+		}
+	}
+	r.emit(ProcessEvent{Type: EventKilled, PID: int(r.pid()), Signal: first})
+
+	for i, stage := range r.ShutdownStages {
+		if stage.Signal != nil {
+			log.Printf("[INFO] shutdown stage %d: sending %q to process %d", i, stage.Signal, r.pid())
+			if err := r.signalKill(stage.Signal); err != nil {
+				log.Printf("[WARN] shutdown stage %d err: %s", i, err)
+			}
+		}
+
+		if r.waitStage(stage) {
+			return
+		}
+	}
+
+	log.Printf("[WARN] process %d outlived all shutdown stages, killing", r.pid())
+	r.forceKill()
+}
+
+// waitStage waits for the process to exit during stage and reports whether
+// it did.
+func (r *Process) waitStage(stage ShutdownStage) bool {
+	timeout := r.after(stage.WaitDuration)
+
+	var poll <-chan time.Time
+	if stage.Condition != nil {
+		t := time.NewTicker(shutdownPollInterval)
+		defer t.Stop()
+		poll = t.C
+	}
+
+	for {
+		select {
+		case <-r.waitCh:
+			return true
+		case <-timeout:
+			return false
+		case <-poll:
+			if stage.Condition() {
+				return false
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package reenvoy
+
+import (
+	"log"
+	"os"
+)
+
+// SignalDeliveryMode selects which processes receive the signals sent to a
+// Process.
+type SignalDeliveryMode int
+
+const (
+	// SignalPID delivers signals to the child process only.
+	SignalPID SignalDeliveryMode = iota
+
+	// SignalPGID runs the child in a process group of its own and delivers
+	// signals to the whole group.
+	SignalPGID
+
+	// SignalAll delivers signals to the child and to every descendant found
+	// through procfs, whatever process group they are in.
+	SignalAll
+)
+
+// signalKill delivers s, sent to stop the process, to its whole process group
+// when KillProcessGroup is set, and as any other signal otherwise.
+func (r *Process) signalKill(s os.Signal) error {
+	if !r.killsGroup() {
+		return r.signal(s)
+	}
+
+	return signalTree(r.exec.Process.Pid, SignalPGID, s)
+}
+
+// killsGroup reports whether the signals stopping the process go to its
+// process group.
+func (r *Process) killsGroup() bool {
+	return r.KillProcessGroup && r.running() && r.podman == nil && r.wasmCancel == nil &&
+		!(r.TestMode && r.TestSignalDelivery != nil)
+}
+
+// forceKill kills the process and, with KillProcessGroup, its group. When
+// the group can't be killed the child is killed alone, so it is never left
+// running once forgotten.
+func (r *Process) forceKill() {
+	err := r.signalKill(os.Kill)
+	if err == nil || !r.killsGroup() || r.exited() {
+		return
+	}
+
+	log.Printf("[WARN] unable to kill process group %d, killing the process only: %s", r.exec.Process.Pid, err)
+	r.exec.Process.Kill()
+}
@@ -0,0 +1,104 @@
+//go:build linux
+// +build linux
+
+package reenvoy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// signalDescendants delivers sig to pid and all of its descendants, found
+// through procfs.
+func signalDescendants(pid int, sig syscall.Signal) error {
+	// Collect the tree first, so processes forked in reaction to the signal
+	// are not chased.
+	children, err := descendants(pid)
+	if err != nil {
+		return err
+	}
+
+	if err := syscall.Kill(pid, sig); err != nil {
+		return err
+	}
+
+	for _, child := range children {
+		if err := syscall.Kill(child, sig); err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("signal descendant %d err: %s", child, err)
+		}
+	}
+	return nil
+}
+
+// descendants returns the PIDs of all processes below pid in the process
+// tree, parents before their children.
+func descendants(pid int) ([]int, error) {
+	children, err := processChildren()
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int
+	queue := children[pid]
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = append(queue[1:], children[p]...)
+		pids = append(pids, p)
+	}
+	return pids, nil
+}
+
+// processChildren returns the PIDs of the children of every process, read
+// from procfs.
+func processChildren() (map[int][]int, error) {
+	stats, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[int][]int)
+	for _, stat := range stats {
+		data, err := ioutil.ReadFile(stat)
+		if err != nil {
+			// The process exited since the glob.
+			continue
+		}
+
+		child, parent, ok := parseStat(string(data))
+		if ok {
+			children[parent] = append(children[parent], child)
+		}
+	}
+	return children, nil
+}
+
+// parseStat returns the PID and parent PID from the content of
+// /proc/PID/stat. The command name is skipped from its last closing
+// parenthesis, as it may contain spaces and parentheses itself.
+func parseStat(stat string) (int, int, bool) {
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0, 0, false
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(stat[:strings.IndexByte(stat, '(')]))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	// The fields after the command name are the state and the parent PID.
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 2 {
+		return 0, 0, false
+	}
+
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	return pid, ppid, true
+}
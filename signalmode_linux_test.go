@@ -0,0 +1,14 @@
+package reenvoy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStat(t *testing.T) {
+	pid, ppid, ok := parseStat("42 (a (b) c) S 7 42 42 0 -1")
+	assert.True(t, ok)
+	assert.Equal(t, 42, pid)
+	assert.Equal(t, 7, ppid)
+}
@@ -0,0 +1,19 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !hurd && !illumos && !ios && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!hurd,!illumos,!ios,!linux,!netbsd,!openbsd,!solaris
+
+package reenvoy
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// setProcessGroup is a no-op without process groups.
+func (r *Process) setProcessGroup(cmd *exec.Cmd) {}
+
+// signalTree is only supported on unix systems.
+func signalTree(pid int, mode SignalDeliveryMode, s os.Signal) error {
+	return fmt.Errorf("signal delivery mode %d is not supported on %s", mode, runtime.GOOS)
+}
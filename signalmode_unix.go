@@ -0,0 +1,38 @@
+//go:build aix || darwin || dragonfly || freebsd || hurd || illumos || ios || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd hurd illumos ios linux netbsd openbsd solaris
+
+package reenvoy
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup makes the child the leader of a new process group when
+// signals are delivered to the group.
+func (r *Process) setProcessGroup(cmd *exec.Cmd) {
+	if r.SignalMode != SignalPGID && !r.KillProcessGroup {
+		return
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalTree delivers s to the process group led by pid, or to pid and all
+// of its descendants, depending on mode.
+func signalTree(pid int, mode SignalDeliveryMode, s os.Signal) error {
+	sig, ok := s.(syscall.Signal)
+	if !ok {
+		return fmt.Errorf("unsupported signal %q", s)
+	}
+
+	if mode == SignalPGID {
+		return syscall.Kill(-pid, sig)
+	}
+	return signalDescendants(pid, sig)
+}
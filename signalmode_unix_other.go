@@ -0,0 +1,15 @@
+//go:build aix || darwin || dragonfly || freebsd || hurd || illumos || ios || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd hurd illumos ios netbsd openbsd solaris
+
+package reenvoy
+
+import (
+	"errors"
+	"syscall"
+)
+
+// signalDescendants needs procfs to find the descendants, which only Linux
+// has.
+func signalDescendants(pid int, sig syscall.Signal) error {
+	return errors.New("signal delivery to all descendants is only supported on linux")
+}
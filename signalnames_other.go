@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package reenvoy
+
+import "syscall"
+
+// signals maps the names of the signals that can be serialized to their
+// values.
+var signals = map[string]syscall.Signal{
+	"SIGABRT":  syscall.SIGABRT,
+	"SIGALRM":  syscall.SIGALRM,
+	"SIGCHLD":  syscall.SIGCHLD,
+	"SIGCONT":  syscall.SIGCONT,
+	"SIGHUP":   syscall.SIGHUP,
+	"SIGINT":   syscall.SIGINT,
+	"SIGKILL":  syscall.SIGKILL,
+	"SIGPIPE":  syscall.SIGPIPE,
+	"SIGQUIT":  syscall.SIGQUIT,
+	"SIGSTOP":  syscall.SIGSTOP,
+	"SIGTERM":  syscall.SIGTERM,
+	"SIGTSTP":  syscall.SIGTSTP,
+	"SIGUSR1":  syscall.SIGUSR1,
+	"SIGUSR2":  syscall.SIGUSR2,
+	"SIGWINCH": syscall.SIGWINCH,
+}
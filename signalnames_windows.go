@@ -0,0 +1,19 @@
+//go:build windows
+// +build windows
+
+package reenvoy
+
+import "syscall"
+
+// signals maps the names of the signals that can be serialized to their
+// values.
+var signals = map[string]syscall.Signal{
+	"SIGABRT": syscall.SIGABRT,
+	"SIGALRM": syscall.SIGALRM,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGPIPE": syscall.SIGPIPE,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGTERM": syscall.SIGTERM,
+}
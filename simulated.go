@@ -0,0 +1,187 @@
+package reenvoy
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// simulatedPID is the last PID given to a SimulatedProcess. The PIDs are above
+// the default pid_max of Linux so they don't look like real processes.
+var simulatedPID int32 = 1 << 22
+
+// OutputLine is a line written by a SimulatedProcess.
+type OutputLine struct {
+	// Delay is how long to wait after the previous line, or the start, before
+	// writing the line.
+	Delay time.Duration
+
+	// Text is written followed by a new line, to StdErr if Stderr is set and
+	// to Stdout otherwise.
+	Text   string
+	Stderr bool
+}
+
+// SimulatedProcess is a Child which runs no command, for testing code
+// managing processes without spawning any. It plays OutputScript and exits
+// with ExitCode after CrashAfter, or runs until it is stopped when CrashAfter
+// is zero. Its ProcessState is always nil.
+type SimulatedProcess struct {
+	sync.Mutex
+
+	CrashAfter   time.Duration
+	ExitCode     int
+	OutputScript []OutputLine
+
+	// SignalBehavior is called when the matching signal is sent with Signal.
+	// Signals without a behavior terminate the process, like their default
+	// disposition.
+	SignalBehavior map[os.Signal]func()
+
+	Stdout io.Writer
+	StdErr io.Writer
+
+	pid    PID
+	exitCh chan int
+	stopCh chan struct{}
+}
+
+var _ Child = (*SimulatedProcess)(nil)
+
+// Start starts a simulated run with a new PID.
+func (s *SimulatedProcess) Start() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.pid != 0 {
+		return ErrAlreadyRunning
+	}
+
+	s.start()
+	return nil
+}
+
+func (s *SimulatedProcess) start() {
+	s.pid = PID(atomic.AddInt32(&simulatedPID, 1))
+	s.exitCh = make(chan int, 1)
+	s.stopCh = make(chan struct{})
+
+	go s.play(s.stopCh)
+	if s.CrashAfter > 0 {
+		go s.crash(s.pid, s.CrashAfter)
+	}
+}
+
+// play writes the OutputScript until stopCh is closed.
+func (s *SimulatedProcess) play(stopCh chan struct{}) {
+	for _, line := range s.OutputScript {
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(line.Delay):
+		}
+
+		w := s.Stdout
+		if line.Stderr {
+			w = s.StdErr
+		}
+		if w != nil {
+			fmt.Fprintln(w, line.Text)
+		}
+	}
+}
+
+// crash makes the run of pid exit with ExitCode after d.
+func (s *SimulatedProcess) crash(pid PID, d time.Duration) {
+	time.Sleep(d)
+
+	s.Lock()
+	defer s.Unlock()
+	if s.pid == pid {
+		s.exit(s.ExitCode, true)
+	}
+}
+
+// exit ends the current run, delivering code on the exit channel if deliver
+// is set. It must be called with the lock held.
+func (s *SimulatedProcess) exit(code int, deliver bool) {
+	if s.pid == 0 {
+		return
+	}
+
+	close(s.stopCh)
+	s.pid = 0
+	if deliver {
+		s.exitCh <- code
+	}
+}
+
+// Restart ends the current run and starts a new one.
+func (s *SimulatedProcess) Restart() error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.exit(-1, false)
+	s.start()
+	return nil
+}
+
+// Stop ends the current run without delivering an exit code, like
+// Process.Stop.
+func (s *SimulatedProcess) Stop() {
+	s.Lock()
+	defer s.Unlock()
+	s.exit(-1, false)
+}
+
+// Kill ends the current run, delivering -1 like a process killed by a
+// signal.
+func (s *SimulatedProcess) Kill() {
+	s.Lock()
+	defer s.Unlock()
+	s.exit(-1, true)
+}
+
+// Signal calls the SignalBehavior of sig, or kills the process when there is
+// none.
+func (s *SimulatedProcess) Signal(sig os.Signal) error {
+	s.Lock()
+	if s.pid == 0 {
+		s.Unlock()
+		return ErrNotRunning
+	}
+
+	fn, ok := s.SignalBehavior[sig]
+	if !ok {
+		s.exit(-1, true)
+		s.Unlock()
+		return nil
+	}
+	s.Unlock()
+
+	// The behavior may call the methods of the process itself.
+	fn()
+	return nil
+}
+
+// ExitCh returns the channel receiving the exit code of the current run.
+func (s *SimulatedProcess) ExitCh() <-chan int {
+	s.Lock()
+	defer s.Unlock()
+	return s.exitCh
+}
+
+// ProcessState returns nil, there is no OS process behind a simulation.
+func (s *SimulatedProcess) ProcessState() *os.ProcessState {
+	return nil
+}
+
+// GetPID returns the simulated PID of the current run, 0 when not running.
+func (s *SimulatedProcess) GetPID() PID {
+	s.Lock()
+	defer s.Unlock()
+	return s.pid
+}
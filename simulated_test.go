@@ -0,0 +1,81 @@
+package reenvoy
+
+import (
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-gatedio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulatedProcess(t *testing.T) {
+	t.Parallel()
+
+	stdout := gatedio.NewByteBuffer()
+	stderr := gatedio.NewByteBuffer()
+	s := &SimulatedProcess{
+		CrashAfter: 100 * time.Millisecond,
+		ExitCode:   3,
+		OutputScript: []OutputLine{
+			{Text: "starting"},
+			{Delay: 20 * time.Millisecond, Text: "oops", Stderr: true},
+			{Delay: time.Second, Text: "never"},
+		},
+		Stdout: stdout,
+		StdErr: stderr,
+	}
+
+	require.Nil(t, s.Start())
+	assert.NotEqual(t, PID(0), s.GetPID())
+	assert.Equal(t, ErrAlreadyRunning, s.Start())
+
+	select {
+	case code := <-s.ExitCh():
+		assert.Equal(t, 3, code)
+	case <-time.After(time.Second):
+		t.Fatal("process should crash")
+	}
+
+	assert.Equal(t, PID(0), s.GetPID())
+	assert.Equal(t, "starting\n", stdout.String())
+	assert.Equal(t, "oops\n", stderr.String())
+}
+
+func TestSimulatedProcess_signals(t *testing.T) {
+	t.Parallel()
+
+	var hups int32
+	s := &SimulatedProcess{
+		SignalBehavior: map[os.Signal]func(){
+			syscall.SIGHUP: func() { atomic.AddInt32(&hups, 1) },
+		},
+	}
+
+	assert.Equal(t, ErrNotRunning, s.Signal(syscall.SIGHUP))
+
+	require.Nil(t, s.Start())
+	pid := s.GetPID()
+	require.Nil(t, s.Signal(syscall.SIGHUP))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hups))
+	assert.Equal(t, pid, s.GetPID())
+
+	require.Nil(t, s.Restart())
+	assert.NotEqual(t, pid, s.GetPID())
+
+	// Signals without a behavior terminate the process.
+	require.Nil(t, s.Signal(syscall.SIGTERM))
+	assert.Equal(t, -1, <-s.ExitCh())
+
+	// Stop does not deliver an exit code.
+	require.Nil(t, s.Start())
+	s.Stop()
+	select {
+	case <-s.ExitCh():
+		t.Fatal("stop should not deliver an exit code")
+	default:
+	}
+}
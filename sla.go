@@ -0,0 +1,182 @@
+package reenvoy
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// uptimeTracker measures the fraction of the uptime window during which the
+// process was running.
+type uptimeTracker struct {
+	sync.Mutex
+
+	// start is the beginning of the current window, up the time spent running
+	// in it before the current run, and since the start of the current run,
+	// zero while the process is down.
+	start time.Time
+	up    time.Duration
+	since time.Time
+
+	// alerted is set once OnSLAViolation fired for the current window; timer
+	// re-evaluates the SLA while the process is down, unless halted because it
+	// was stopped or killed on purpose.
+	alerted bool
+	timer   *time.Timer
+	halted  bool
+
+	// waitCh is the wait channel of the current run, for the exit of a child
+	// replaced by a restart not to mark its successor down.
+	waitCh chan struct{}
+}
+
+// CurrentUptime returns the fraction, between 0 and 1, of the current
+// UptimeWindow during which the process was running.
+func (r *Process) CurrentUptime() float64 {
+	t := &r.uptime
+	t.Lock()
+	defer t.Unlock()
+
+	now := time.Now()
+	t.roll(now, r.UptimeWindow)
+	return t.fraction(now)
+}
+
+// SLAViolation reports whether the uptime of the current window is below
+// SLATarget.
+func (r *Process) SLAViolation() bool {
+	return r.SLATarget > 0 && r.CurrentUptime() < r.SLATarget
+}
+
+// markUp records that the run of waitCh started.
+func (r *Process) markUp(waitCh chan struct{}) {
+	t := &r.uptime
+	t.Lock()
+	defer t.Unlock()
+
+	t.waitCh = waitCh
+
+	now := time.Now()
+	t.roll(now, r.UptimeWindow)
+	if t.since.IsZero() {
+		t.since = now
+	}
+	t.halted = false
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+// haltSLA stops re-evaluating the SLA until the process runs again, as it was
+// stopped or killed on purpose.
+func (r *Process) haltSLA() {
+	t := &r.uptime
+	t.Lock()
+	defer t.Unlock()
+
+	t.halted = true
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+// markDown records that the run of waitCh ended and checks the SLA, unless a
+// newer run started meanwhile.
+func (r *Process) markDown(waitCh chan struct{}) {
+	t := &r.uptime
+	t.Lock()
+	if t.waitCh != waitCh {
+		t.Unlock()
+		return
+	}
+
+	now := time.Now()
+	t.roll(now, r.UptimeWindow)
+	if !t.since.IsZero() {
+		t.up += now.Sub(t.since)
+		t.since = time.Time{}
+	}
+
+	r.checkSLA(now)
+}
+
+// checkSLA must be called with the tracker locked, which it releases. It
+// calls OnSLAViolation when the SLA is breached and, while the process stays
+// down, schedules itself for the moment the SLA would be breached or the
+// window ends.
+func (r *Process) checkSLA(now time.Time) {
+	t := &r.uptime
+	if r.SLATarget <= 0 || t.alerted {
+		t.Unlock()
+		return
+	}
+
+	actual := t.fraction(now)
+	if actual < r.SLATarget {
+		t.alerted = true
+		t.Unlock()
+
+		log.Printf("[WARN] uptime %.4f is below the SLA target %.4f", actual, r.SLATarget)
+		if r.OnSLAViolation != nil {
+			r.OnSLAViolation(r.SLATarget, actual)
+		}
+		return
+	}
+
+	if t.since.IsZero() && !t.halted {
+		// Uptime only decreases while down; it reaches the target once the
+		// window has grown to up/target.
+		next := t.start.Add(time.Duration(float64(t.up)/r.SLATarget) + 1)
+		if end := t.start.Add(r.UptimeWindow); r.UptimeWindow > 0 && end.Before(next) {
+			next = end
+		}
+
+		if t.timer != nil {
+			t.timer.Stop()
+		}
+		t.timer = time.AfterFunc(next.Sub(now), func() {
+			t.Lock()
+			if t.halted {
+				t.Unlock()
+				return
+			}
+			t.roll(time.Now(), r.UptimeWindow)
+			r.checkSLA(time.Now())
+		})
+	}
+	t.Unlock()
+}
+
+// roll starts a new window once UptimeWindow has elapsed.
+func (t *uptimeTracker) roll(now time.Time, window time.Duration) {
+	if t.start.IsZero() {
+		t.start = now
+		return
+	}
+
+	if window <= 0 || now.Sub(t.start) < window {
+		return
+	}
+
+	t.start = now
+	t.up = 0
+	t.alerted = false
+	if !t.since.IsZero() {
+		t.since = now
+	}
+}
+
+func (t *uptimeTracker) fraction(now time.Time) float64 {
+	elapsed := now.Sub(t.start)
+	if elapsed <= 0 {
+		return 1
+	}
+
+	up := t.up
+	if !t.since.IsZero() {
+		up += now.Sub(t.since)
+	}
+	return float64(up) / float64(elapsed)
+}
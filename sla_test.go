@@ -0,0 +1,144 @@
+package reenvoy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSLAViolation(t *testing.T) {
+	t.Parallel()
+
+	violations := make(chan float64, 1)
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "sleep 0.3"}
+	c.SLATarget = 0.5
+	c.UptimeWindow = time.Minute
+	c.OnSLAViolation = func(target, actual float64) {
+		violations <- actual
+	}
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	assert.False(t, c.SLAViolation())
+
+	// Up for ~300ms, then down: the SLA is breached ~300ms after the exit.
+	select {
+	case actual := <-violations:
+		assert.True(t, actual < 0.5)
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected an SLA violation")
+	}
+
+	assert.True(t, c.SLAViolation())
+	assert.True(t, c.CurrentUptime() < 0.5)
+}
+
+func TestUptimeTracker_roll(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	tr := uptimeTracker{}
+	tr.roll(now, time.Minute)
+	tr.since = now
+
+	assert.InDelta(t, 1, tr.fraction(now.Add(time.Second)), 0.001)
+
+	tr.since = time.Time{}
+	tr.up = 30 * time.Second
+	assert.InDelta(t, 0.5, tr.fraction(now.Add(time.Minute)), 0.001)
+
+	tr.alerted = true
+	tr.roll(now.Add(time.Minute), time.Minute)
+	assert.False(t, tr.alerted)
+	assert.Equal(t, time.Duration(0), tr.up)
+}
+
+func TestSLAViolation_stop(t *testing.T) {
+	t.Parallel()
+
+	violations := make(chan float64, 1)
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "while true; do sleep 0.2; done"}
+	c.SLATarget = 0.5
+	c.UptimeWindow = time.Minute
+	c.OnSLAViolation = func(target, actual float64) {
+		violations <- actual
+	}
+
+	require.Nil(t, c.Start())
+	time.Sleep(300 * time.Millisecond)
+
+	// Stopped on purpose, the downtime doesn't count against the SLA.
+	c.Stop()
+	select {
+	case actual := <-violations:
+		t.Fatalf("unexpected SLA violation %.4f", actual)
+	case <-time.After(time.Second):
+	}
+}
+
+func TestSLAViolation_restart(t *testing.T) {
+	t.Parallel()
+
+	violations := make(chan float64, 1)
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "while true; do sleep 0.2; done"}
+	c.ReloadSignal = nil
+	c.SLATarget = 0.9
+	c.UptimeWindow = time.Minute
+	c.OnSLAViolation = func(target, actual float64) {
+		violations <- actual
+	}
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	// The exit of a replaced child must not mark its successor down.
+	for i := 0; i < 10; i++ {
+		time.Sleep(50 * time.Millisecond)
+		require.Nil(t, c.Restart())
+	}
+
+	select {
+	case actual := <-violations:
+		t.Fatalf("unexpected SLA violation %.4f", actual)
+	case <-time.After(time.Second):
+	}
+	assert.True(t, c.CurrentUptime() > 0.9)
+}
+
+func TestSLAViolation_stopWithDeadline(t *testing.T) {
+	t.Parallel()
+
+	violations := make(chan float64, 1)
+
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "while true; do sleep 0.2; done"}
+	c.ReloadSignal = nil
+	c.SLATarget = 0.5
+	c.UptimeWindow = time.Minute
+	c.OnSLAViolation = func(target, actual float64) {
+		violations <- actual
+	}
+
+	require.Nil(t, c.Start())
+	time.Sleep(300 * time.Millisecond)
+
+	require.Nil(t, c.StopWithDeadline(time.Now().Add(5*time.Second)))
+	select {
+	case actual := <-violations:
+		t.Fatalf("unexpected SLA violation %.4f", actual)
+	case <-time.After(time.Second):
+	}
+}
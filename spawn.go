@@ -2,6 +2,7 @@ package reenvoy
 
 import (
 	"io"
+	"os"
 	"time"
 )
 
@@ -16,6 +17,16 @@ type SpawnOptions struct {
 	DoneCh     chan struct{}
 	ConfigPath string
 
+	// Command and Args are the command to run instead of envoy, see
+	// Process.Command.
+	Command string
+	Args    []string
+
+	// ReloadSignal and KillSignal are the signals sent to reload and to stop
+	// the process, see Process.
+	ReloadSignal os.Signal
+	KillSignal   os.Signal
+
 	// Env specifies the environment of the process.
 	// Each entry is of the form "key=value".
 	// If Env is nil, the new process uses the current process's
@@ -49,6 +60,10 @@ type SpawnOptions struct {
 func SpawnProcess(opt SpawnOptions, restartEpoch int) (*Process, error) {
 	opt = defaultOptions(opt)
 	p := &Process{
+		Command:             opt.Command,
+		Args:                opt.Args,
+		ReloadSignal:        opt.ReloadSignal,
+		KillSignal:          opt.KillSignal,
 		Env:                 opt.Env,
 		Timeout:             opt.Timeout,
 		KillTimeout:         opt.KillTimeout,
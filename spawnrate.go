@@ -0,0 +1,65 @@
+package reenvoy
+
+import (
+	"log"
+	"time"
+)
+
+// recordSpawn counts a spawn of the process and reports when more than
+// MaxSpawnRate happened within SpawnRateMonitorWindow. It must be called with
+// the lock held.
+func (r *Process) recordSpawn() {
+	if r.MaxSpawnRate <= 0 || r.SpawnRateMonitorWindow <= 0 {
+		return
+	}
+
+	now := r.now()
+	r.spawns = append(r.recentSpawns(now), now)
+
+	if count := len(r.spawns); count > r.MaxSpawnRate {
+		log.Printf("[WARN] process spawned %d times in %s, max is %d", count, r.SpawnRateMonitorWindow, r.MaxSpawnRate)
+		if r.OnSpawnRateExceeded != nil {
+			go r.OnSpawnRateExceeded(count)
+		}
+	}
+}
+
+// recentSpawns returns the spawns within SpawnRateMonitorWindow before now.
+func (r *Process) recentSpawns(now time.Time) []time.Time {
+	cutoff := now.Add(-r.SpawnRateMonitorWindow)
+	for i, t := range r.spawns {
+		if t.After(cutoff) {
+			return r.spawns[i:]
+		}
+	}
+	return r.spawns[:0]
+}
+
+// SpawnRateExceeded reports whether the process was spawned more than
+// MaxSpawnRate times within the last SpawnRateMonitorWindow.
+func (r *Process) SpawnRateExceeded() bool {
+	r.RLock()
+	defer r.RUnlock()
+
+	if r.MaxSpawnRate <= 0 || r.SpawnRateMonitorWindow <= 0 {
+		return false
+	}
+	return len(r.recentSpawns(r.now())) > r.MaxSpawnRate
+}
+
+// spawnRatePause returns how long to wait from now before the next spawn
+// stays within MaxSpawnRate. It must be called with the lock held.
+func (r *Process) spawnRatePause(now time.Time) time.Duration {
+	if r.MaxSpawnRate <= 0 || r.SpawnRateMonitorWindow <= 0 {
+		return 0
+	}
+
+	recent := r.recentSpawns(now)
+	if len(recent) < r.MaxSpawnRate {
+		return 0
+	}
+
+	// Enough of the oldest spawns must leave the window to make room.
+	oldest := recent[len(recent)-r.MaxSpawnRate]
+	return oldest.Add(r.SpawnRateMonitorWindow).Sub(now)
+}
@@ -0,0 +1,68 @@
+package reenvoy
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// defaultStartupPollInterval is the interval between two calls to the
+// StartupProbe when StartupPollInterval is not set.
+const defaultStartupPollInterval = 200 * time.Millisecond
+
+// waitStartup polls the StartupProbe until it succeeds, the child behind
+// waitCh exits or the StartupTimeout is exceeded, in which cases the child is
+// killed and the returned error wraps the last error of the probe. It must be
+// called without the lock held, so the process can be stopped in the
+// meantime.
+func (r *Process) waitStartup(waitCh <-chan struct{}) error {
+	if r.StartupProbe == nil {
+		return nil
+	}
+
+	interval := r.StartupPollInterval
+	if interval <= 0 {
+		interval = defaultStartupPollInterval
+	}
+
+	var timeout <-chan time.Time
+	if r.StartupTimeout > 0 {
+		timeout = r.after(r.StartupTimeout)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	begin := r.now()
+	for {
+		err := r.StartupProbe()
+		if err == nil {
+			log.Printf("[DEBUG] startup probe succeeded after %s", r.now().Sub(begin))
+			return nil
+		}
+
+		select {
+		case <-waitCh:
+			r.abortStartup(waitCh)
+			return fmt.Errorf("process exited before its startup probe succeeded, after %s err: %w", r.now().Sub(begin), err)
+		case <-timeout:
+			r.abortStartup(waitCh)
+			return fmt.Errorf("startup probe did not succeed within %s err: %w", r.now().Sub(begin), err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// abortStartup kills the child behind waitCh which failed its startup probe,
+// unless it was stopped or replaced meanwhile, so a later Start starts it
+// again.
+func (r *Process) abortStartup(waitCh <-chan struct{}) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.waitCh != waitCh || !r.running() {
+		return
+	}
+	r.kill()
+	r.removePIDFile()
+}
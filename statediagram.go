@@ -0,0 +1,83 @@
+package reenvoy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StateDiagram returns a Mermaid state diagram of the lifecycle of the
+// process. Transitions depending on the configuration, like the automatic
+// restarts or the startup probe, are only included when configured.
+func (r *Process) StateDiagram() string {
+	r.RLock()
+	defer r.RUnlock()
+
+	var b strings.Builder
+	transition := func(from, to, label string) {
+		if label == "" {
+			fmt.Fprintf(&b, "    %s --> %s\n", from, to)
+			return
+		}
+		fmt.Fprintf(&b, "    %s --> %s: %s\n", from, to, label)
+	}
+
+	b.WriteString("stateDiagram-v2\n")
+	transition("[*]", "Idle", "")
+
+	if r.StartTrigger != nil {
+		transition("Idle", "WaitingTrigger", "Start")
+		transition("WaitingTrigger", "Starting", "start trigger fired")
+	} else {
+		transition("Idle", "Starting", "Start")
+	}
+
+	if r.StartupProbe != nil {
+		transition("Starting", "Running", "startup probe succeeded")
+		if r.StartupTimeout > 0 {
+			transition("Starting", "Running", "startup timeout, Start fails")
+		}
+		transition("Starting", "Exited", "exited before the probe succeeded")
+	} else {
+		transition("Starting", "Running", "started")
+	}
+
+	if r.ReloadSignal != nil {
+		transition("Running", "Reloading", "Restart")
+		if r.ReloadTimeout > 0 {
+			transition("Reloading", "Running", "reload acknowledged")
+			transition("Reloading", "Stopping", "reload timeout")
+		} else {
+			transition("Reloading", "Running", "reload signal sent")
+		}
+	} else {
+		transition("Running", "Stopping", "Restart")
+		transition("Stopping", "Starting", "restarting")
+	}
+
+	transition("Running", "Stopping", "Stop or Kill")
+	if r.StopTrigger != nil {
+		transition("Running", "Stopping", "stop trigger fired")
+	}
+	if r.Timeout > 0 {
+		transition("Running", "Stopping", "timeout")
+	}
+
+	if len(r.ShutdownStages) > 0 {
+		transition("Stopping", "ShuttingDown", "")
+		transition("ShuttingDown", "ShuttingDown", "next shutdown stage")
+		transition("ShuttingDown", "Idle", "exited or killed")
+	} else {
+		transition("Stopping", "Idle", "exited or killed")
+	}
+
+	transition("Running", "Exited", "exited on its own")
+	if r.MaxRestarts != 0 {
+		transition("Exited", "Backoff", "automatic restart")
+		transition("Backoff", "Starting", "restart delay elapsed")
+		transition("Backoff", "Idle", "Stop")
+	}
+	transition("Exited", "Idle", "Stop")
+	transition("Idle", "[*]", "")
+
+	return b.String()
+}
@@ -0,0 +1,31 @@
+package reenvoy
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateDiagram(t *testing.T) {
+	t.Parallel()
+
+	c := testProcess(t)
+	d := c.StateDiagram()
+	assert.True(t, strings.HasPrefix(d, "stateDiagram-v2\n"))
+	assert.Contains(t, d, "Idle --> Starting: Start\n")
+	assert.Contains(t, d, "Running --> Reloading: Restart\n")
+	assert.NotContains(t, d, "Backoff")
+	assert.NotContains(t, d, "WaitingTrigger")
+
+	c.MaxRestarts = -1
+	c.StartTrigger = TimeTrigger("0 4 * * *")
+	c.ReloadSignal = nil
+	c.ReloadTimeout = time.Second
+	d = c.StateDiagram()
+	assert.Contains(t, d, "Exited --> Backoff: automatic restart\n")
+	assert.Contains(t, d, "Idle --> WaitingTrigger: Start\n")
+	assert.Contains(t, d, "Running --> Stopping: Restart\n")
+	assert.NotContains(t, d, "Reloading")
+}
@@ -0,0 +1,96 @@
+package reenvoy
+
+import (
+	"io"
+	"time"
+)
+
+// input returns the reader for the standard input of the child, decoded from
+// StdinEncoding and throttled to StdinRateLimit bytes per second when set.
+func (r *Process) input() io.Reader {
+	stdin := decodeReader(r.Stdin, r.StdinEncoding)
+	if stdin == nil || r.StdinRateLimit <= 0 {
+		return stdin
+	}
+	return newThrottledReader(stdin, r.StdinRateLimit)
+}
+
+// throttledReader is a token bucket over an io.Reader. The bucket holds up to
+// one second worth of bytes and every read spends the bytes it returns,
+// sleeping until the bucket is no longer in debt.
+type throttledReader struct {
+	r      io.Reader
+	rate   int
+	tokens float64
+	last   time.Time
+	sleep  func(time.Duration)
+}
+
+func newThrottledReader(r io.Reader, rate int) *throttledReader {
+	return &throttledReader{
+		r:      r,
+		rate:   rate,
+		tokens: float64(rate),
+		last:   time.Now(),
+		sleep:  time.Sleep,
+	}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > t.rate {
+		p = p[:t.rate]
+	}
+
+	n, err := t.r.Read(p)
+	t.take(n)
+	return n, err
+}
+
+// take refills the bucket for the time elapsed since the last read, spends n
+// tokens and waits out any resulting debt.
+func (t *throttledReader) take(n int) {
+	now := time.Now()
+	t.tokens += now.Sub(t.last).Seconds() * float64(t.rate)
+	if max := float64(t.rate); t.tokens > max {
+		t.tokens = max
+	}
+	t.last = now
+
+	t.tokens -= float64(n)
+	if t.tokens < 0 {
+		t.sleep(time.Duration(-t.tokens / float64(t.rate) * float64(time.Second)))
+		t.tokens, t.last = 0, time.Now()
+	}
+}
+
+// SendInput writes data to the standard input of the running child. It is
+// only available to local commands started without a Stdin, whose standard
+// input is then a pipe; ErrStdinNotPiped is returned otherwise.
+func (r *Process) SendInput(data []byte) (int, error) {
+	r.RLock()
+	stdin, pipe, waitCh := r.Stdin, r.stdinPipe, r.waitCh
+	running := r.running()
+	r.RUnlock()
+
+	if stdin != nil {
+		return 0, ErrStdinNotPiped
+	}
+
+	if !running {
+		return 0, ErrNotRunning
+	}
+
+	select {
+	case <-waitCh:
+		return 0, ErrNotRunning
+	default:
+	}
+
+	if pipe == nil {
+		return 0, ErrStdinNotPiped
+	}
+
+	// The lock is not held while writing, as the child may not read its
+	// input right away.
+	return pipe.Write(data)
+}
@@ -0,0 +1,80 @@
+package reenvoy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-gatedio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottledReader(t *testing.T) {
+	t.Parallel()
+
+	var slept time.Duration
+	tr := newThrottledReader(strings.NewReader(strings.Repeat("x", 400)), 100)
+	tr.sleep = func(d time.Duration) { slept += d }
+
+	data, err := ioutil.ReadAll(tr)
+	require.Nil(t, err)
+	assert.Len(t, data, 400)
+
+	// The first second is covered by the initial burst, the remaining 300
+	// bytes need about three seconds.
+	assert.InDelta(t, 3*time.Second, slept, float64(100*time.Millisecond))
+}
+
+func TestStdinRateLimit(t *testing.T) {
+	t.Parallel()
+
+	out := new(bytes.Buffer)
+	c := testProcess(t)
+	c.Command = "cat"
+	c.Args = nil
+	c.Stdin = strings.NewReader(strings.Repeat("x", 150))
+	c.Stdout = out
+	c.StdinRateLimit = 100
+
+	begin := time.Now()
+	require.Nil(t, c.Start())
+	<-c.ExitCh()
+
+	assert.True(t, time.Since(begin) >= 400*time.Millisecond, "stdin should be throttled")
+	assert.Equal(t, 150, out.Len())
+}
+
+func TestSendInput(t *testing.T) {
+	t.Parallel()
+
+	stdout := gatedio.NewByteBuffer()
+	c := testProcess(t)
+	c.Command = "cat"
+	c.Args = nil
+	c.Stdout = stdout
+
+	_, err := c.SendInput([]byte("hello\n"))
+	assert.Equal(t, ErrNotRunning, err)
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	n, err := c.SendInput([]byte("hello\n"))
+	require.Nil(t, err)
+	assert.Equal(t, 6, n)
+
+	time.Sleep(fileWaitSleepDelay)
+	assert.Equal(t, "hello\n", stdout.String())
+
+	c.Kill()
+	_, err = c.SendInput([]byte("hello\n"))
+	assert.Equal(t, ErrNotRunning, err)
+
+	// A Stdin given by the caller is not piped.
+	c.Stdin = strings.NewReader("")
+	_, err = c.SendInput([]byte("hello\n"))
+	assert.Equal(t, ErrStdinNotPiped, err)
+}
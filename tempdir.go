@@ -0,0 +1,55 @@
+package reenvoy
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// prepareTempDir removes anything left in TempDir by a previous run and
+// creates it again empty, so every start begins with clean state.
+func (r *Process) prepareTempDir() error {
+	if r.TempDir == "" {
+		return nil
+	}
+
+	if err := os.RemoveAll(r.TempDir); err != nil {
+		return fmt.Errorf("remove temp dir %s err: %s", r.TempDir, err)
+	}
+
+	if err := os.MkdirAll(r.TempDir, 0700); err != nil {
+		return fmt.Errorf("create temp dir %s err: %s", r.TempDir, err)
+	}
+
+	return nil
+}
+
+// removeTempDir deletes TempDir once the process is gone.
+func (r *Process) removeTempDir() {
+	if r.TempDir == "" {
+		return
+	}
+
+	if err := os.RemoveAll(r.TempDir); err != nil {
+		log.Printf("[WARN] failed to remove temp dir %s: %s", r.TempDir, err)
+	}
+}
+
+// tempDirEnv returns env extended with the variables pointing the child at
+// TempDir. A nil env is expanded to the current environment first so the
+// child keeps inheriting it.
+func (r *Process) tempDirEnv(env []string) []string {
+	if r.TempDir == "" {
+		return env
+	}
+
+	if env == nil {
+		env = os.Environ()
+	}
+
+	return append(env[:len(env):len(env)],
+		"TMPDIR="+r.TempDir,
+		"XDG_RUNTIME_DIR="+r.TempDir,
+		"REENVOY_TMPDIR="+r.TempDir,
+	)
+}
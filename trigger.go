@@ -0,0 +1,286 @@
+package reenvoy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// triggerPollInterval is how often the file and HTTP triggers check whether
+// they fired.
+var triggerPollInterval = time.Second
+
+// Trigger is an external event Start waits for before starting the process.
+type Trigger interface {
+	// Wait blocks until the event happens, or ctx is done.
+	Wait(ctx context.Context) error
+}
+
+// FileTrigger returns a Trigger firing once path exists.
+func FileTrigger(path string) Trigger {
+	return pollTrigger(func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	})
+}
+
+// HTTPTrigger returns a Trigger firing once a GET request to url succeeds with
+// a 2xx status.
+func HTTPTrigger(url string) Trigger {
+	return pollTrigger(func() bool {
+		resp, err := http.Get(url)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode >= 200 && resp.StatusCode < 300
+	})
+}
+
+// pollTrigger fires once its function reports true, checking every
+// triggerPollInterval.
+type pollTrigger func() bool
+
+func (t pollTrigger) Wait(ctx context.Context) error {
+	ticker := time.NewTicker(triggerPollInterval)
+	defer ticker.Stop()
+
+	for !t() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// SignalTrigger returns a Trigger firing once the current process receives
+// sig.
+func SignalTrigger(sig os.Signal) Trigger {
+	return signalTrigger{sig}
+}
+
+type signalTrigger struct {
+	sig os.Signal
+}
+
+func (t signalTrigger) Wait(ctx context.Context) error {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, t.sig)
+	defer signal.Stop(ch)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-ch:
+		return nil
+	}
+}
+
+// TimeTrigger returns a Trigger firing at the next time matching the cron
+// expression spec, in local time. spec has the five standard fields: minute,
+// hour, day of month, month and day of week, each being *, a value, a range
+// or a comma separated list of those, optionally with a /step.
+func TimeTrigger(spec string) Trigger {
+	return timeTrigger(spec)
+}
+
+type timeTrigger string
+
+func (t timeTrigger) Wait(ctx context.Context) error {
+	schedule, err := parseCron(string(t))
+	if err != nil {
+		return err
+	}
+
+	next := schedule.next(time.Now())
+	if next.IsZero() {
+		return fmt.Errorf("cron expression %q never matches", string(t))
+	}
+
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// cronSchedule holds the values matched by each field of a cron expression,
+// as bit sets.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// When both the day of month and the day of week are restricted, a day
+	// matching either of them matches, as in cron.
+	domAny, dowAny bool
+}
+
+// parseCron parses a five field cron expression.
+func parseCron(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields", spec)
+	}
+
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	var sets [5]uint64
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q err: %s", spec, err)
+		}
+		sets[i] = set
+	}
+
+	// Sunday is both 0 and 7.
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+
+	return &cronSchedule{
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		domAny: strings.HasPrefix(fields[2], "*"),
+		dowAny: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// parseCronField returns the set of values between min and max matched by
+// field.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			var err error
+			bounds := strings.SplitN(part, "-", 2)
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// next returns the first time after t matching the schedule, or the zero time
+// if none matches within five years.
+func (s *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *cronSchedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+
+	switch {
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// waitStartTrigger waits for StartTrigger to fire, unless Stop or Kill call
+// cancel meanwhile.
+func (r *Process) waitStartTrigger(ctx context.Context, cancel context.CancelFunc) error {
+	r.Lock()
+	r.cancelStart = cancel
+	r.Unlock()
+
+	log.Printf("[INFO] waiting for start trigger")
+	if err := r.StartTrigger.Wait(ctx); err != nil {
+		return fmt.Errorf("start trigger err: %s", err)
+	}
+	return nil
+}
+
+// cancelStartTrigger cuts short the wait of Start for StartTrigger, if any.
+// It must be called with the lock held.
+func (r *Process) cancelStartTrigger() {
+	if r.cancelStart != nil {
+		r.cancelStart()
+		r.cancelStart = nil
+	}
+}
+
+// watchStopTrigger stops the process once the StopTrigger fires. The watch
+// ends when the process exits.
+func (r *Process) watchStopTrigger(waitCh <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-waitCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := r.StopTrigger.Wait(ctx); err != nil {
+		if ctx.Err() == nil {
+			log.Printf("[WARN] stop trigger err: %s", err)
+		}
+		return
+	}
+
+	log.Printf("[INFO] stop trigger fired, stopping process")
+	r.Stop()
+}
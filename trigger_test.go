@@ -0,0 +1,210 @@
+package reenvoy
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileTrigger(t *testing.T) {
+	defer func(d time.Duration) { triggerPollInterval = d }(triggerPollInterval)
+	triggerPollInterval = 10 * time.Millisecond
+
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "go")
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "sleep 10"}
+	c.StartTrigger = FileTrigger(path)
+	defer c.Stop()
+
+	started := make(chan error, 1)
+	go func() { started <- c.Start() }()
+
+	select {
+	case <-started:
+		t.Fatal("start should wait for the file")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.Nil(t, ioutil.WriteFile(path, nil, 0644))
+	select {
+	case err := <-started:
+		require.Nil(t, err)
+		assert.NotEqual(t, PID(0), c.GetPID())
+	case <-time.After(time.Second):
+		t.Fatal("start should begin once the file exists")
+	}
+}
+
+func TestFileTrigger_stop(t *testing.T) {
+	defer func(d time.Duration) { triggerPollInterval = d }(triggerPollInterval)
+	triggerPollInterval = 10 * time.Millisecond
+
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "go")
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "sleep 10"}
+	c.StartTrigger = FileTrigger(path)
+
+	started := make(chan error, 1)
+	go func() { started <- c.Start() }()
+
+	time.Sleep(50 * time.Millisecond)
+	c.Stop()
+
+	select {
+	case err := <-started:
+		assert.NotNil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("stop should cancel the pending start")
+	}
+
+	// The trigger firing later doesn't start the process anymore.
+	require.Nil(t, ioutil.WriteFile(path, nil, 0644))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, PID(0), c.GetPID())
+}
+
+func TestHTTPTrigger(t *testing.T) {
+	defer func(d time.Duration) { triggerPollInterval = d }(triggerPollInterval)
+	triggerPollInterval = 10 * time.Millisecond
+
+	var ready int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&ready) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, HTTPTrigger(srv.URL).Wait(ctx))
+
+	atomic.StoreInt32(&ready, 1)
+	assert.Nil(t, HTTPTrigger(srv.URL).Wait(context.Background()))
+}
+
+func TestSignalTrigger(t *testing.T) {
+	done := make(chan error, 1)
+	go func() { done <- SignalTrigger(syscall.SIGUSR2).Wait(context.Background()) }()
+
+	// Let the trigger subscribe to the signal.
+	time.Sleep(50 * time.Millisecond)
+	require.Nil(t, syscall.Kill(os.Getpid(), syscall.SIGUSR2))
+
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("trigger should fire on the signal")
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.ParseInLocation("2006-01-02 15:04", s, time.UTC)
+		require.Nil(t, err)
+		return tm
+	}
+
+	cases := []struct {
+		spec string
+		from string
+		next string
+	}{
+		{"* * * * *", "2024-03-10 12:30", "2024-03-10 12:31"},
+		{"*/15 * * * *", "2024-03-10 12:31", "2024-03-10 12:45"},
+		{"0 9-17 * * *", "2024-03-10 17:00", "2024-03-11 09:00"},
+		{"30 4 1,15 * *", "2024-03-10 12:00", "2024-03-15 04:30"},
+		{"0 0 * * 7", "2024-03-10 12:00", "2024-03-17 00:00"},
+		{"0 0 29 2 *", "2024-03-01 00:00", "2028-02-29 00:00"},
+		// Either the day of month or the day of week matches.
+		{"0 0 1 * 1", "2024-03-10 12:00", "2024-03-11 00:00"},
+	}
+
+	for _, tc := range cases {
+		s, err := parseCron(tc.spec)
+		require.Nil(t, err, tc.spec)
+		assert.Equal(t, at(tc.next), s.next(at(tc.from)), tc.spec)
+	}
+
+	for _, spec := range []string{"* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		_, err := parseCron(spec)
+		assert.NotNil(t, err, spec)
+	}
+
+	s, err := parseCron("0 0 30 2 *")
+	require.Nil(t, err)
+	assert.True(t, s.next(at("2024-01-01 00:00")).IsZero())
+}
+
+func TestStopTrigger(t *testing.T) {
+	defer func(d time.Duration) { triggerPollInterval = d }(triggerPollInterval)
+	triggerPollInterval = 10 * time.Millisecond
+
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "stop")
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "sleep 10"}
+	c.StopTrigger = FileTrigger(path)
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.NotEqual(t, PID(0), c.GetPID())
+
+	require.Nil(t, ioutil.WriteFile(path, nil, 0644))
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, PID(0), c.GetPID())
+}
+
+func TestStopTrigger_restart(t *testing.T) {
+	defer func(d time.Duration) { triggerPollInterval = d }(triggerPollInterval)
+	triggerPollInterval = 10 * time.Millisecond
+
+	dir, err := ioutil.TempDir("", "reenvoy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "stop")
+	c := testProcess(t)
+	c.Command = "bash"
+	c.Args = []string{"-c", "sleep 10"}
+	c.StopTrigger = FileTrigger(path)
+	defer c.Stop()
+
+	// Every start is undone by the next firing of the trigger.
+	for i := 0; i < 2; i++ {
+		require.Nil(t, c.Start())
+		time.Sleep(50 * time.Millisecond)
+		assert.NotEqual(t, PID(0), c.GetPID())
+
+		require.Nil(t, ioutil.WriteFile(path, nil, 0644))
+		time.Sleep(200 * time.Millisecond)
+		assert.Equal(t, PID(0), c.GetPID())
+		require.Nil(t, os.Remove(path))
+	}
+}
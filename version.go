@@ -0,0 +1,26 @@
+package reenvoy
+
+import "runtime"
+
+// Version, GitCommit and BuildDate describe the build. They are set at link
+// time, e.g.
+//
+//	go build -ldflags "-X github.com/evo3cx/reenvoy.Version=v1.2.0 \
+//		-X github.com/evo3cx/reenvoy.GitCommit=$(git rev-parse --short HEAD) \
+//		-X github.com/evo3cx/reenvoy.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Version   = "dev"
+	GitCommit = "unknown"
+	BuildDate = "unknown"
+)
+
+// BuildInfo returns the version, git commit and build date of the build and
+// the Go version it was built with.
+func BuildInfo() map[string]string {
+	return map[string]string{
+		"version":    Version,
+		"git_commit": GitCommit,
+		"build_date": BuildDate,
+		"go_version": runtime.Version(),
+	}
+}
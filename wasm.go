@@ -0,0 +1,75 @@
+package reenvoy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// WASMRuntime executes WebAssembly modules for processes with a WASMFile. It
+// abstracts over engines such as wasmtime, wasmer or wazero, which callers
+// wire in by implementing this interface.
+type WASMRuntime interface {
+	// Run compiles the module at file and executes it with WASI, passing it
+	// args, env and the standard streams. It blocks until the module exits and
+	// returns its exit code. Run must return promptly once ctx is cancelled.
+	Run(ctx context.Context, file string, args, env []string, stdin io.Reader, stdout, stderr io.Writer) (int, error)
+}
+
+// startWASM runs WASMFile on WASMRuntime in the background and watches it
+// until it exits.
+func (r *Process) startWASM() error {
+	if r.WASMRuntime == nil {
+		return fmt.Errorf("no WASMRuntime given to run %s", r.WASMFile)
+	}
+
+	if _, err := os.Stat(r.WASMFile); err != nil {
+		return fmt.Errorf("wasm module err: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rt, file, args := r.WASMRuntime, r.WASMFile, r.Args
+	env := r.childEnv()
+	stdin := r.input()
+	stdout, stderr := r.outputs()
+
+	log.Printf("[INFO] running wasm module %s", file)
+
+	r.wasmCancel = cancel
+	r.watch(func() (int, *os.ProcessState, error) {
+		defer cancel()
+
+		code, err := rt.Run(ctx, file, args, env, stdin, stdout, stderr)
+		if err != nil {
+			log.Printf("[WARN] wasm module %s err: %s", file, err)
+			return ExitCodeError, nil, err
+		}
+		return code, nil, nil
+	})
+
+	return nil
+}
+
+// signalWASM emulates the kill signals by cancelling the module; any other
+// signal can not be delivered to it.
+func (r *Process) signalWASM(s os.Signal) error {
+	if s != os.Kill && s != r.KillSignal {
+		return fmt.Errorf("signal %q is not supported by wasm modules", s)
+	}
+
+	r.wasmCancel()
+	return nil
+}
+
+// killWASM cancels the module and waits up to KillTimeout for it to return.
+func (r *Process) killWASM() {
+	r.wasmCancel()
+
+	select {
+	case <-r.waitCh:
+	case <-r.after(r.KillTimeout):
+		log.Printf("[WARN] wasm module %s did not stop within %s", r.WASMFile, r.KillTimeout)
+	}
+}
@@ -0,0 +1,88 @@
+package reenvoy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-gatedio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoRuntime pretends to run a module printing its environment, then either
+// exits with code or blocks until cancelled.
+type echoRuntime struct {
+	code  int
+	block bool
+}
+
+func (e echoRuntime) Run(ctx context.Context, file string, args, env []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	fmt.Fprintln(stdout, env)
+	if e.block {
+		<-ctx.Done()
+		return 1, nil
+	}
+	return e.code, nil
+}
+
+func testModule(t *testing.T) string {
+	f, err := ioutil.TempFile("", "reenvoy-*.wasm")
+	require.Nil(t, err)
+	f.Close()
+	return f.Name()
+}
+
+func TestWASM(t *testing.T) {
+	t.Parallel()
+
+	module := testModule(t)
+	defer os.Remove(module)
+
+	c := testProcess(t)
+	c.WASMFile = module
+	c.WASMRuntime = echoRuntime{code: 3}
+	c.Env = []string{"a=b"}
+
+	out := gatedio.NewByteBuffer()
+	c.Stdout = out
+
+	require.Nil(t, c.Start())
+	defer c.Stop()
+
+	select {
+	case code := <-c.ExitCh():
+		assert.Equal(t, 3, code)
+	case <-time.After(fileWaitSleepDelay):
+		t.Fatal("module should have exited")
+	}
+	assert.Equal(t, "[a=b]\n", out.String())
+}
+
+func TestWASM_kill(t *testing.T) {
+	t.Parallel()
+
+	module := testModule(t)
+	defer os.Remove(module)
+
+	c := testProcess(t)
+	c.WASMFile = module
+	c.WASMRuntime = echoRuntime{block: true}
+	c.Stdout = ioutil.Discard
+
+	require.Nil(t, c.Start())
+	assert.True(t, c.running())
+
+	c.Kill()
+	assert.False(t, c.running())
+
+	select {
+	case <-c.ExitCh():
+	case <-time.After(fileWaitSleepDelay):
+		t.Fatal("module should have been cancelled")
+	}
+}
@@ -0,0 +1,111 @@
+package reenvoy
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// ZoneCoordinator keeps the zones of a deployment from restarting their
+// processes at the same time.
+type ZoneCoordinator interface {
+	// BeginRestart waits until no other zone is restarting, or until ctx is
+	// done, and marks zone as restarting.
+	BeginRestart(ctx context.Context, zone string) error
+
+	// EndRestart marks zone as done restarting.
+	EndRestart(zone string) error
+}
+
+// beginZoneRestart waits up to ZoneRestartTimeout for the other zones to
+// finish restarting and returns the function to call once the restart is
+// over. The restart proceeds when the wait fails.
+func (r *Process) beginZoneRestart() func() {
+	if r.ZoneCoordinator == nil {
+		log.Printf("[WARN] zone aware restart without a ZoneCoordinator")
+		return func() {}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.ZoneRestartTimeout)
+	defer cancel()
+
+	if err := r.ZoneCoordinator.BeginRestart(ctx, r.ZoneID); err != nil {
+		log.Printf("[WARN] zone %s restarting without coordination: %s", r.ZoneID, err)
+		return func() {}
+	}
+
+	return func() {
+		if err := r.ZoneCoordinator.EndRestart(r.ZoneID); err != nil {
+			log.Printf("[WARN] zone %s failed to end restart: %s", r.ZoneID, err)
+		}
+	}
+}
+
+// consulCoordinator lets one zone at a time hold a Consul lock while it
+// restarts.
+type consulCoordinator struct {
+	client *api.Client
+	key    string
+
+	mu    sync.Mutex
+	locks map[string]*api.Lock
+}
+
+// NewConsulZoneCoordinator returns a ZoneCoordinator using the Consul lock at
+// key, which must be shared by all zones.
+func NewConsulZoneCoordinator(client *api.Client, key string) ZoneCoordinator {
+	return &consulCoordinator{
+		client: client,
+		key:    key,
+		locks:  make(map[string]*api.Lock),
+	}
+}
+
+func (c *consulCoordinator) BeginRestart(ctx context.Context, zone string) error {
+	lock, err := c.client.LockOpts(&api.LockOptions{
+		Key:         c.key,
+		Value:       []byte(zone),
+		SessionName: "reenvoy restart " + zone,
+	})
+	if err != nil {
+		return err
+	}
+
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			close(stopCh)
+		case <-done:
+		}
+	}()
+
+	held, err := lock.Lock(stopCh)
+	if err != nil {
+		return err
+	}
+	if held == nil {
+		return ctx.Err()
+	}
+
+	c.mu.Lock()
+	c.locks[zone] = lock
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *consulCoordinator) EndRestart(zone string) error {
+	c.mu.Lock()
+	lock, ok := c.locks[zone]
+	delete(c.locks, zone)
+	c.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return lock.Unlock()
+}